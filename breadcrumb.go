@@ -0,0 +1,103 @@
+package ierror
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxBreadcrumbs 每个请求默认最多保留的面包屑条数
+const defaultMaxBreadcrumbs = 32
+
+// Crumb 一条面包屑，记录错误发生前请求中做过的事情
+type Crumb struct {
+	Time   time.Time              `json:"time"`
+	Msg    string                 `json:"msg"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// crumbLog 保存在ctx中的有界面包屑日志，超出容量时丢弃最早的记录
+type crumbLog struct {
+	mu    sync.Mutex
+	max   int
+	items []Crumb
+}
+
+type crumbKey struct{}
+
+// WithBreadcrumbs 返回带有面包屑日志的ctx，通常在请求入口调用一次
+// max<=0 时使用默认容量
+func WithBreadcrumbs(ctx context.Context, max int) context.Context {
+	if max <= 0 {
+		max = defaultMaxBreadcrumbs
+	}
+	return context.WithValue(ctx, crumbKey{}, &crumbLog{max: max})
+}
+
+// Breadcrumb 向ctx中的面包屑日志追加一条记录，ctx没有经过WithBreadcrumbs时什么也不做
+func Breadcrumb(ctx context.Context, msg string, fields map[string]interface{}) {
+	l, ok := ctx.Value(crumbKey{}).(*crumbLog)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	if len(l.items) == l.max {
+		copy(l.items, l.items[1:])
+		l.items = l.items[:l.max-1]
+	}
+	l.items = append(l.items, Crumb{Time: time.Now(), Msg: msg, Fields: fields})
+	l.mu.Unlock()
+}
+
+// snapshotCrumbs 复制ctx中当前的面包屑，供带ctx的构造函数附加到错误上
+func snapshotCrumbs(ctx context.Context) []Crumb {
+	l, ok := ctx.Value(crumbKey{}).(*crumbLog)
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.items) == 0 {
+		return nil
+	}
+	return append([]Crumb(nil), l.items...)
+}
+
+// Breadcrumbs 返回错误链上最外层附带的面包屑
+func Breadcrumbs(err error) []Crumb {
+	for e := err; e != nil; {
+		ge, ok := e.(*IError)
+		if !ok {
+			return nil
+		}
+		if len(ge.crumbs) > 0 {
+			return ge.crumbs
+		}
+		e = ge.Err
+	}
+	return nil
+}
+
+// traceCrumbs 将面包屑格式化为Trace末尾的附加段
+func traceCrumbs(crumbs []Crumb) string {
+	if len(crumbs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\nbreadcrumbs :")
+	for _, c := range crumbs {
+		fmt.Fprintf(&sb, "\n\t%s %s", c.Time.Format("15:04:05.000"), c.Msg)
+		keys := make([]string, 0, len(c.Fields))
+		for k := range c.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, " %s=%v", k, c.Fields[k])
+		}
+	}
+	return sb.String()
+}
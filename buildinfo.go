@@ -0,0 +1,107 @@
+package ierror
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// BuildInfo 部署相关的信息，附加在序列化后的错误和Trace的头部
+type BuildInfo struct {
+	Service   string `json:"service,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Module    string `json:"module,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Dirty     bool   `json:"dirty,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+}
+
+var (
+	buildOnce sync.Once
+	buildMu   sync.RWMutex
+	build     BuildInfo
+
+	buildInJSON  atomic.Bool
+	buildInTrace atomic.Bool
+)
+
+// loadBuildInfo 从runtime/debug.ReadBuildInfo中读取模块版本和VCS信息，只读取一次
+func loadBuildInfo() {
+	buildOnce.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		buildMu.Lock()
+		defer buildMu.Unlock()
+		build.Module = info.Main.Path
+		build.Version = info.Main.Version
+		build.GoVersion = info.GoVersion
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				build.Revision = s.Value
+			case "vcs.modified":
+				build.Dirty = s.Value == "true"
+			}
+		}
+	})
+}
+
+// SetService 设置服务名和实例标识，通常在启动时调用一次
+func SetService(name, instance string) {
+	loadBuildInfo()
+	buildMu.Lock()
+	build.Service, build.Instance = name, instance
+	buildMu.Unlock()
+}
+
+// GetBuildInfo 返回当前进程的部署信息
+func GetBuildInfo() BuildInfo {
+	loadBuildInfo()
+	buildMu.RLock()
+	defer buildMu.RUnlock()
+	return build
+}
+
+// EnableBuildInfo 设置是否在序列化的错误（JSON）和Trace的头部中附带部署信息，默认都不附带
+func EnableBuildInfo(inJSON, inTrace bool) {
+	buildInJSON.Store(inJSON)
+	buildInTrace.Store(inTrace)
+}
+
+// jsonBuildInfo 开启时返回序列化用的部署信息
+func jsonBuildInfo() *BuildInfo {
+	if !buildInJSON.Load() {
+		return nil
+	}
+	b := GetBuildInfo()
+	return &b
+}
+
+// traceBuildHeader 开启时返回Trace头部的部署信息行
+func traceBuildHeader() string {
+	if !buildInTrace.Load() {
+		return ""
+	}
+	b := GetBuildInfo()
+	var parts []string
+	add := func(k, v string) {
+		if v != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	add("service", b.Service)
+	add("instance", b.Instance)
+	add("module", b.Module)
+	add("version", b.Version)
+	add("revision", b.Revision)
+	if b.Dirty {
+		add("dirty", "true")
+	}
+	add("go", b.GoVersion)
+	return "\nbuild : " + strings.Join(parts, " ")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RanFeng/ierror"
+)
+
+// catalog 错误码目录文件的结构
+type catalog struct {
+	// Package 生成代码的包名，命令行的 -package 和 go:generate 设置的 $GOPACKAGE 优先
+	Package string         `json:"package"`
+	Codes   []catalogEntry `json:"codes"`
+}
+
+// catalogEntry 目录中的一个错误码
+type catalogEntry struct {
+	Code    int    `json:"code"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	// Params 消息模板中参数的Go类型，未列出的参数为string
+	Params    map[string]string `json:"params"`
+	Family    string            `json:"family"`
+	HTTP      int               `json:"http"`
+	GRPC      grpcCode          `json:"grpc"`
+	Retryable bool              `json:"retryable"`
+	// Translations 各语言的消息模板，使用与Message相同的参数
+	Translations map[string]string `json:"translations"`
+}
+
+// info 转换为注册和导出用的元信息
+func (e catalogEntry) info() ierror.CodeInfo {
+	return ierror.CodeInfo{
+		Code:         e.Code,
+		Name:         e.Name,
+		Message:      e.Message,
+		Family:       e.Family,
+		HTTP:         e.HTTP,
+		GRPC:         int(e.GRPC),
+		Retryable:    e.Retryable,
+		Translations: e.Translations,
+	}
+}
+
+// grpcCode gRPC状态码，目录中可以写数字或名称，例如 5 或 "NotFound"
+type grpcCode int
+
+var grpcNames = []string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound",
+	"AlreadyExists", "PermissionDenied", "ResourceExhausted", "FailedPrecondition",
+	"Aborted", "OutOfRange", "Unimplemented", "Internal", "Unavailable", "DataLoss",
+	"Unauthenticated",
+}
+
+func (c *grpcCode) UnmarshalJSON(b []byte) error {
+	var name string
+	if json.Unmarshal(b, &name) != nil {
+		var n int
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("grpc: want a number or a code name, got %s", b)
+		}
+		*c = grpcCode(n)
+		return nil
+	}
+	for i, s := range grpcNames {
+		// 同时接受 NOT_FOUND 形式的名称
+		if strings.EqualFold(s, strings.ReplaceAll(name, "_", "")) {
+			*c = grpcCode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("grpc: unknown code %q", name)
+}
+
+// paramPattern 消息模板中的参数
+var paramPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// param 构造函数的一个参数
+type param struct {
+	Name string
+	Type string
+}
+
+// Args 按在消息模板中第一次出现的顺序返回构造函数的参数
+func (e catalogEntry) Args() []param {
+	var out []param
+	seen := map[string]bool{}
+	for _, m := range paramPattern.FindAllStringSubmatch(e.Message, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		typ := e.Params[m[1]]
+		if typ == "" {
+			typ = "string"
+		}
+		out = append(out, param{Name: m[1], Type: typ})
+	}
+	return out
+}
+
+// Format 将消息模板转换为fmt.Sprintf的格式，参数以显式下标引用，可以重复出现
+func (e catalogEntry) Format() string {
+	index := map[string]int{}
+	for i, p := range e.Args() {
+		index[p.Name] = i + 1
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range paramPattern.FindAllStringSubmatchIndex(e.Message, -1) {
+		sb.WriteString(strings.ReplaceAll(e.Message[last:m[0]], "%", "%%"))
+		sb.WriteString("%[" + strconv.Itoa(index[e.Message[m[2]:m[3]]]) + "]v")
+		last = m[1]
+	}
+	sb.WriteString(strings.ReplaceAll(e.Message[last:], "%", "%%"))
+	return sb.String()
+}
+
+// builtinTypes 参数可以使用的类型，生成的代码不需要额外的import
+var builtinTypes = map[string]bool{
+	"string": true, "bool": true, "error": true, "any": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "byte": true, "rune": true,
+}
+
+// reservedParams 生成的代码中已经使用的名字，预声明的标识符（int、string、len等）另由types.Universe检查
+var reservedParams = map[string]bool{"err": true, "context": true, "fmt": true, "ierror": true}
+
+// readCatalog 读取并检查目录文件，返回的错误码按错误码排序
+func readCatalog(path string) (*catalog, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCatalog(path, b)
+}
+
+// parseCatalog 解析并检查目录文件的内容，扩展名为 .yaml 或 .yml 时按YAML解析，否则按JSON解析
+func parseCatalog(path string, b []byte) (*catalog, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var err error
+		if b, err = yamlToJSON(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	var c catalog
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	codes, names := map[int]string{}, map[string]bool{}
+	for _, e := range c.Codes {
+		where := fmt.Sprintf("%s: code %d", path, e.Code)
+		switch {
+		case !token.IsIdentifier(e.Name) || !token.IsExported(e.Name):
+			return nil, fmt.Errorf("%s: name %q is not an exported Go identifier", where, e.Name)
+		case codes[e.Code] != "":
+			return nil, fmt.Errorf("%s: duplicate code, also used by %s", where, codes[e.Code])
+		case names[e.Name]:
+			return nil, fmt.Errorf("%s: duplicate name %s", where, e.Name)
+		case e.Code == ierror.Success || e.Code == ierror.ErrUnknown:
+			return nil, fmt.Errorf("%s: code is reserved by ierror", where)
+		}
+		codes[e.Code], names[e.Name] = e.Name, true
+		for name := range e.Params {
+			if !strings.Contains(e.Message, "{"+name+"}") {
+				return nil, fmt.Errorf("%s: param %s is not used in the message", where, name)
+			}
+		}
+		for lang, t := range e.Translations {
+			for _, m := range paramPattern.FindAllStringSubmatch(t, -1) {
+				if !strings.Contains(e.Message, m[0]) {
+					return nil, fmt.Errorf("%s: translation %s uses param %s that is not in the message", where, lang, m[1])
+				}
+			}
+		}
+		for _, p := range e.Args() {
+			if !token.IsIdentifier(p.Name) || token.IsKeyword(p.Name) || reservedParams[p.Name] ||
+				types.Universe.Lookup(p.Name) != nil {
+				return nil, fmt.Errorf("%s: invalid param name %q", where, p.Name)
+			}
+			if !builtinTypes[p.Type] {
+				return nil, fmt.Errorf("%s: param %s has unsupported type %q", where, p.Name, p.Type)
+			}
+		}
+	}
+	if len(c.Codes) == 0 {
+		return nil, fmt.Errorf("%s: no codes", path)
+	}
+	sort.Slice(c.Codes, func(i, j int) bool { return c.Codes[i].Code < c.Codes[j].Code })
+	return &c, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// generate 生成错误码的Go代码，source为写入文件头部的目录文件名
+func generate(pkg, source string, c *catalog) ([]byte, error) {
+	var buf bytes.Buffer
+	err := goTmpl.Execute(&buf, struct {
+		Package string
+		Source  string
+		Codes   []catalogEntry
+	}{pkg, source, c.Codes})
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, buf.Bytes())
+	}
+	return out, nil
+}
+
+var goTmpl = template.Must(template.New("go").Funcs(template.FuncMap{
+	"comment": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	"sortedKeys": func(m map[string]string) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}).Parse(`// Code generated by ierrgen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RanFeng/ierror"
+)
+
+// Code 错误码
+type Code int
+
+// 错误码
+const (
+{{- range .Codes}}
+	// Code{{.Name}} {{comment .Message}}
+	Code{{.Name}} Code = {{.Code}}
+{{- end}}
+)
+
+// String 返回错误码的名称
+func (c Code) String() string {
+	switch c {
+{{- range .Codes}}
+	case Code{{.Name}}:
+		return {{printf "%q" .Name}}
+{{- end}}
+	}
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// 哨兵错误，errors.Is 按错误码匹配
+var (
+{{- range .Codes}}
+	Err{{.Name}} = ierror.Sentinel(int(Code{{.Name}}), {{printf "%q" .Message}})
+{{- end}}
+)
+{{range .Codes}}{{$params := .Args}}
+// New{{.Name}} 创建{{.Name}}错误：{{comment .Message}}
+func New{{.Name}}({{range $i, $p := $params}}{{if $i}}, {{end}}{{$p.Name}} {{$p.Type}}{{end}}) *ierror.IError {
+	return ierror.NewIErrorSkip(context.Background(), 1, int(Code{{.Name}}), {{template "msg" .}})
+}
+
+// Wrap{{.Name}} 以{{.Name}}错误封装err
+func Wrap{{.Name}}(err error{{range $params}}, {{.Name}} {{.Type}}{{end}}) *ierror.IError {
+	return ierror.WrapIErrorSkip(context.Background(), 1, err, int(Code{{.Name}}), {{template "msg" .}})
+}
+{{end}}
+func init() {
+{{- range .Codes}}
+	ierror.RegisterCode(ierror.CodeInfo{
+		Code:    {{.Code}},
+		Name:    {{printf "%q" .Name}},
+		Message: {{printf "%q" .Message}},
+{{- if .Family}}
+		Family:  {{printf "%q" .Family}},
+{{- end}}
+{{- if .HTTP}}
+		HTTP:    {{.HTTP}},
+{{- end}}
+{{- if .GRPC}}
+		GRPC:    {{printf "%d" .GRPC}},
+{{- end}}
+{{- if .Retryable}}
+		Retryable: true,
+{{- end}}
+{{- if .Translations}}
+		Translations: map[string]string{
+{{- $t := .Translations}}
+{{- range sortedKeys $t}}
+			{{printf "%q" .}}: {{printf "%q" (index $t .)}},
+{{- end}}
+		},
+{{- end}}
+	})
+{{- end}}
+}
+{{define "msg"}}{{if .Args}}fmt.Sprintf({{printf "%q" .Format}}{{range .Args}}, {{.Name}}{{end}}){{else}}{{printf "%q" .Message}}{{end}}{{end}}`))
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	c, err := readCatalog(filepath.Join("testdata", "catalog.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate("errcode", "catalog.json", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "catalog_gen.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generated code differs from %s, run go test -update\n%s", golden, got)
+	}
+
+	// 生成的代码必须能通过类型检查
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "catalog_gen.go", got, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	info := &types.Info{Defs: map[*ast.Ident]types.Object{}}
+	pkg, err := conf.Check("errcode", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, sig := range map[string]string{
+		"NewUserNotFound":  "func(id int64) *github.com/RanFeng/ierror.IError",
+		"WrapBadInput":     "func(err error, field string, table string, value float64) *github.com/RanFeng/ierror.IError",
+		"NewDBTimeout":     "func() *github.com/RanFeng/ierror.IError",
+		"ErrUserNotFound":  "*github.com/RanFeng/ierror.IError",
+		"CodeUserNotFound": "errcode.Code",
+	} {
+		obj := pkg.Scope().Lookup(name)
+		if obj == nil {
+			t.Errorf("%s is not declared", name)
+			continue
+		}
+		if got := obj.Type().String(); got != sig {
+			t.Errorf("%s: type %s, want %s", name, got, sig)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	e := catalogEntry{Message: "{b} and {a}, {b} again, 50%"}
+	if got, want := e.Format(), "%[1]v and %[2]v, %[1]v again, 50%%"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestParseCatalogInvalid(t *testing.T) {
+	for _, tt := range []struct{ name, json string }{
+		{"predeclared param", `{"codes": [{"code": 1, "name": "A", "message": "{len}"}]}`},
+		{"keyword param", `{"codes": [{"code": 1, "name": "A", "message": "{func}"}]}`},
+		{"reserved param", `{"codes": [{"code": 1, "name": "A", "message": "{err}"}]}`},
+		{"unknown type", `{"codes": [{"code": 1, "name": "A", "message": "{x}", "params": {"x": "time.Time"}}]}`},
+		{"unused param", `{"codes": [{"code": 1, "name": "A", "message": "x", "params": {"x": "int"}}]}`},
+		{"duplicate code", `{"codes": [{"code": 1, "name": "A", "message": "x"}, {"code": 1, "name": "B", "message": "y"}]}`},
+		{"unexported name", `{"codes": [{"code": 1, "name": "a", "message": "x"}]}`},
+		{"reserved code", `{"codes": [{"code": 0, "name": "A", "message": "x"}]}`},
+		{"unknown grpc", `{"codes": [{"code": 1, "name": "A", "message": "x", "grpc": "Nope"}]}`},
+		{"translation param", `{"codes": [{"code": 1, "name": "A", "message": "x", "translations": {"en": "{y}"}}]}`},
+		{"empty", `{"codes": []}`},
+	} {
+		if _, err := parseCatalog("errors.json", []byte(tt.json)); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
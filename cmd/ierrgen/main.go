@@ -0,0 +1,133 @@
+// Command ierrgen 根据错误码目录文件生成Go代码
+//
+// 生成的代码包含错误码常量、哨兵错误、带类型参数的构造函数，并在init中将错误码注册到ierror，
+// 通常配合go:generate使用：
+//
+//	//go:generate go run github.com/RanFeng/ierror/cmd/ierrgen -catalog errors.json
+//
+// 目录文件为JSON，扩展名为 .yaml 或 .yml 时按YAML解析：
+//
+//	{
+//	  "package": "errcode",
+//	  "codes": [
+//	    {
+//	      "code": 40401,
+//	      "name": "UserNotFound",
+//	      "message": "user {id} not found",
+//	      "params": {"id": "int64"},
+//	      "family": "user",
+//	      "http": 404,
+//	      "grpc": "NotFound",
+//	      "retryable": false,
+//	      "translations": {"zh-CN": "用户 {id} 不存在"}
+//	    }
+//	  ]
+//	}
+//
+// YAML目录的结构相同，以 { 开头的消息需要加引号：
+//
+//	package: errcode
+//	codes:
+//	  - code: 40401
+//	    name: UserNotFound
+//	    message: "user {id} not found"
+//	    params: {id: int64}
+//	    grpc: NotFound
+//	    translations:
+//	      zh-CN: 用户 {id} 不存在
+//
+// 消息模板中的 {name} 是构造函数的参数，按第一次出现的顺序排列，类型由params指定，默认为string
+//
+// -ts 和 -openapi 同时导出供前端使用的TypeScript定义和OpenAPI的components片段，
+// 只需要导出时可以用 -go=false 跳过Go代码；运行中的服务也可以用 ierror.WriteTypeScript 和
+// ierror.WriteOpenAPI 导出 ierror.Codes() 中已注册的错误码
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RanFeng/ierror"
+)
+
+func main() {
+	catalogPath := flag.String("catalog", "errors.json", "error catalog file")
+	output := flag.String("o", "", "output file (default: <catalog>_gen.go next to the catalog)")
+	pkg := flag.String("package", "", "package name (default: $GOPACKAGE, then the catalog's package)")
+	goOut := flag.Bool("go", true, "generate Go code")
+	tsOut := flag.String("ts", "", "also write TypeScript definitions to this file")
+	openAPIOut := flag.String("openapi", "", "also write an OpenAPI components fragment (JSON) to this file")
+	flag.Parse()
+
+	err := run(*catalogPath, *output, *pkg, *goOut)
+	if err == nil && (*tsOut != "" || *openAPIOut != "") {
+		err = export(*catalogPath, *tsOut, *openAPIOut)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ierrgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(catalogPath, output, pkg string, goOut bool) error {
+	if !goOut {
+		return nil
+	}
+	c, err := readCatalog(catalogPath)
+	if err != nil {
+		return err
+	}
+	if pkg == "" {
+		pkg = os.Getenv("GOPACKAGE")
+	}
+	if pkg == "" {
+		pkg = c.Package
+	}
+	if pkg == "" {
+		return fmt.Errorf("%s: no package name, set -package", catalogPath)
+	}
+	if output == "" {
+		output = strings.TrimSuffix(catalogPath, filepath.Ext(catalogPath)) + "_gen.go"
+	}
+	src, err := generate(pkg, filepath.Base(catalogPath), c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
+
+// export 将目录中的错误码导出为TypeScript和OpenAPI定义，文件名为空时跳过
+func export(catalogPath, tsOut, openAPIOut string) error {
+	c, err := readCatalog(catalogPath)
+	if err != nil {
+		return err
+	}
+	codes := make([]ierror.CodeInfo, 0, len(c.Codes))
+	for _, e := range c.Codes {
+		codes = append(codes, e.info())
+	}
+	for _, out := range []struct {
+		path  string
+		write func(io.Writer, []ierror.CodeInfo) error
+	}{
+		{tsOut, ierror.WriteTypeScript},
+		{openAPIOut, ierror.WriteOpenAPI},
+	} {
+		if out.path == "" {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := out.write(&buf, codes); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out.path, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
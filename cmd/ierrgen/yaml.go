@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlToJSON 将YAML格式的目录转换为JSON，之后与JSON目录走相同的解码和检查
+// 只支持目录用得到的子集：块映射和块序列、流式的 {} 和 []、单双引号和普通标量、| 和 > 块标量、注释；
+// 不支持锚点、别名、标签和多文档
+func yamlToJSON(b []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(raw) != "" && strings.Contains(raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))], "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{no: i + 1, raw: raw})
+	}
+	p.skipBlank()
+	if p.eof() {
+		return []byte("null"), nil
+	}
+	v, err := p.node(p.cur().indent())
+	if err != nil {
+		return nil, err
+	}
+	if p.skipBlank(); !p.eof() {
+		return nil, p.errorf("unexpected %q", strings.TrimSpace(p.cur().raw))
+	}
+	return json.Marshal(v)
+}
+
+type yamlLine struct {
+	no  int
+	raw string
+}
+
+func (l yamlLine) indent() int {
+	return len(l.raw) - len(strings.TrimLeft(l.raw, " "))
+}
+
+// text 去掉缩进和注释后的内容
+func (l yamlLine) text() string {
+	return stripComment(strings.TrimSpace(l.raw))
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) eof() bool     { return p.pos >= len(p.lines) }
+func (p *yamlParser) cur() yamlLine { return p.lines[p.pos] }
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	no := len(p.lines)
+	if !p.eof() {
+		no = p.cur().no
+	}
+	return fmt.Errorf("yaml line %d: %s", no, fmt.Sprintf(format, args...))
+}
+
+// skipBlank 跳过空行、注释行和文档开始标记
+func (p *yamlParser) skipBlank() {
+	for !p.eof() {
+		t := p.cur().text()
+		if t != "" && t != "---" {
+			return
+		}
+		p.pos++
+	}
+}
+
+// node 解析缩进为indent的块映射或块序列
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	if isSeqItem(p.cur().text()) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isSeqItem(t string) bool {
+	return t == "-" || strings.HasPrefix(t, "- ")
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	out := []interface{}{}
+	for p.skipBlank(); !p.eof() && p.cur().indent() == indent && isSeqItem(p.cur().text()); p.skipBlank() {
+		l := p.cur()
+		rest := strings.TrimSpace(strings.TrimPrefix(l.text(), "-"))
+		if rest == "" {
+			p.pos++
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok {
+			// "- key: value" 开始一个映射，其余的键与key对齐
+			inner := indent + strings.Index(l.raw[indent:], rest)
+			p.lines[p.pos].raw = strings.Repeat(" ", inner) + l.raw[inner:]
+			v, err := p.mapping(inner)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		v, err := p.inline(rest)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if !p.eof() && p.cur().indent() > indent {
+		return nil, p.errorf("bad indentation")
+	}
+	return out, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	out := map[string]interface{}{}
+	for p.skipBlank(); !p.eof() && p.cur().indent() == indent; p.skipBlank() {
+		t := p.cur().text()
+		if isSeqItem(t) {
+			break
+		}
+		key, rest, ok := splitKey(t)
+		if !ok {
+			return nil, p.errorf("expected key: value, got %q", t)
+		}
+		if _, dup := out[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		var (
+			v   interface{}
+			err error
+		)
+		switch {
+		case rest == "":
+			p.pos++
+			// 值可以是与键对齐的序列
+			if p.skipBlank(); !p.eof() && p.cur().indent() == indent && isSeqItem(p.cur().text()) {
+				v, err = p.sequence(indent)
+			} else {
+				v, err = p.nested(indent)
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			v, err = p.blockScalar(indent, rest)
+		default:
+			v, err = p.inline(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	if !p.eof() && p.cur().indent() > indent {
+		return nil, p.errorf("bad indentation")
+	}
+	return out, nil
+}
+
+// nested 解析缩进大于parent的子节点，没有子节点时为null
+func (p *yamlParser) nested(parent int) (interface{}, error) {
+	if p.skipBlank(); p.eof() || p.cur().indent() <= parent {
+		return nil, nil
+	}
+	return p.node(p.cur().indent())
+}
+
+// inline 解析同一行中的值，消耗当前行
+func (p *yamlParser) inline(s string) (interface{}, error) {
+	f := &flowParser{s: s}
+	v, err := f.value(false)
+	if err == nil {
+		if f.skipSpace(); f.i < len(f.s) {
+			err = fmt.Errorf("unexpected %q", f.s[f.i:])
+		}
+	}
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.pos++
+	return v, nil
+}
+
+// blockScalar 解析 | 或 > 开始的多行字符串，header为指示符，例如 |-
+func (p *yamlParser) blockScalar(parent int, header string) (interface{}, error) {
+	folded, chomp := header[0] == '>', header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf("unsupported block scalar header %q", header)
+	}
+	p.pos++
+	var lines []string
+	indent := -1
+	for ; !p.eof(); p.pos++ {
+		raw := p.cur().raw
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := yamlLine{raw: raw}.indent()
+		if indent < 0 {
+			indent = n
+		}
+		if n <= parent || n < indent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+	}
+	// 结尾的空行属于后面的内容，由chomp决定是否保留换行
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines, trailing = lines[:len(lines)-1], trailing+1
+	}
+	var s string
+	if folded {
+		// 相邻的非空行以空格连接，空行表示换行
+		for i, l := range lines {
+			switch {
+			case l == "":
+				s += "\n"
+			case i > 0 && lines[i-1] != "":
+				s += " "
+			}
+			s += l
+		}
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	switch {
+	case chomp == "+":
+		s += strings.Repeat("\n", trailing+1)
+	case chomp == "" && len(lines) > 0:
+		s += "\n"
+	}
+	return s, nil
+}
+
+// splitKey 拆分 key: value，value可能为空
+func splitKey(t string) (key, rest string, ok bool) {
+	if strings.HasPrefix(t, `"`) || strings.HasPrefix(t, "'") {
+		f := &flowParser{s: t}
+		k, err := f.quoted()
+		if err != nil || !strings.HasPrefix(f.s[f.i:], ":") {
+			return "", "", false
+		}
+		rest = f.s[f.i+1:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(rest), true
+	}
+	if strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+		return "", "", false
+	}
+	i := strings.Index(t, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(t, ":") {
+			return "", "", false
+		}
+		i = len(t) - 1
+	}
+	return strings.TrimSpace(t[:i]), strings.TrimSpace(t[i+1:]), true
+}
+
+// stripComment 去掉引号之外以 # 开始的注释
+func stripComment(t string) string {
+	var quote byte
+	for i := 0; i < len(t); i++ {
+		c := t[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" :[{,", t[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || t[i-1] == ' ' || t[i-1] == '\t'):
+			return strings.TrimSpace(t[:i])
+		}
+	}
+	return t
+}
+
+// flowParser 解析一行中的流式值：{}、[]、引号字符串和普通标量
+type flowParser struct {
+	s string
+	i int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+// value inFlow为true时普通标量在 , ] } 处结束
+func (f *flowParser) value(inFlow bool) (interface{}, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '{':
+		return f.flowMap()
+	case '[':
+		return f.flowSeq()
+	case '"', '\'':
+		return f.quoted()
+	case '&', '*', '!', '|', '>', '@', '`':
+		return nil, fmt.Errorf("unsupported %q", f.s[f.i:])
+	}
+	start := f.i
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if inFlow && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if c == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') && inFlow {
+			break
+		}
+		f.i++
+	}
+	return plainScalar(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *flowParser) flowMap() (interface{}, error) {
+	out := map[string]interface{}{}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return out, nil
+		}
+		k, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.i >= len(f.s) || f.s[f.i] != ':' {
+			return nil, fmt.Errorf("expected ':' in flow mapping")
+		}
+		f.i++
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		out[fmt.Sprint(k)] = v
+		if err := f.flowNext('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flowParser) flowSeq() (interface{}, error) {
+	out := []interface{}{}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return out, nil
+		}
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		if err := f.flowNext(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// flowNext 消耗元素之间的逗号，遇到结束符时留给调用方
+func (f *flowParser) flowNext(end byte) error {
+	f.skipSpace()
+	switch {
+	case f.i >= len(f.s):
+		return fmt.Errorf("missing %q", end)
+	case f.s[f.i] == ',':
+		f.i++
+	case f.s[f.i] != end:
+		return fmt.Errorf("unexpected %q", f.s[f.i:])
+	}
+	return nil
+}
+
+func (f *flowParser) quoted() (string, error) {
+	q := f.s[f.i]
+	for j := f.i + 1; j < len(f.s); j++ {
+		switch {
+		case q == '\'' && f.s[j] == '\'':
+			if j+1 < len(f.s) && f.s[j+1] == '\'' {
+				j++
+				continue
+			}
+			s := strings.ReplaceAll(f.s[f.i+1:j], "''", "'")
+			f.i = j + 1
+			return s, nil
+		case q == '"' && f.s[j] == '\\':
+			j++
+		case q == '"' && f.s[j] == '"':
+			s, err := strconv.Unquote(f.s[f.i : j+1])
+			if err != nil {
+				return "", fmt.Errorf("bad string %s", f.s[f.i:j+1])
+			}
+			f.i = j + 1
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string %s", f.s[f.i:])
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// plainScalar 按YAML的核心schema解析普通标量
+func plainScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlInt.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10))
+		}
+	}
+	if yamlFloat.MatchString(s) {
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(strconv.FormatFloat(n, 'g', -1, 64))
+		}
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name, yaml, json string
+	}{
+		{"scalars", `
+# comment
+a: 1   # trailing comment
+b: -2.50
+c: true
+d: ~
+e: plain text with: colon
+f: "double \"quoted\" # not a comment\n"
+g: 'single ''quoted'''
+h: user's #tag
+`, `{"a":1,"b":-2.5,"c":true,"d":null,"e":"plain text with: colon","f":"double \"quoted\" # not a comment\n","g":"single 'quoted'","h":"user's"}`},
+		{"nesting", `
+codes:
+- code: 1
+  params: {id: int64, "n": [1, two]}
+  tags:
+    - x
+    -
+      y: 2
+- code: 2
+  empty:
+`, `{"codes":[{"code":1,"params":{"id":"int64","n":[1,"two"]},"tags":["x",{"y":2}]},{"code":2,"empty":null}]}`},
+		{"block scalars", `
+lit: |
+  a
+    b
+
+  c
+folded: >-
+  a
+  b
+
+  c
+keep: |+
+  x
+
+end: 1
+`, `{"end":1,"folded":"a b\nc","keep":"x\n\n","lit":"a\n  b\n\nc\n"}`},
+	}
+	for _, tt := range tests {
+		got, err := yamlToJSON([]byte(tt.yaml))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var g, w interface{}
+		if err := json.Unmarshal(got, &g); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		_ = json.Unmarshal([]byte(tt.json), &w)
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.json)
+		}
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	for _, src := range []string{
+		"a: 1\na: 2",
+		"a: 1\n  b: 2",
+		"a: {id: int64",
+		"a: \"open",
+		"a: &anchor 1",
+		"a:\n\t- 1",
+		"just a scalar line",
+	} {
+		if _, err := yamlToJSON([]byte(src)); err == nil {
+			t.Errorf("yamlToJSON(%q) succeeded", src)
+		} else if !strings.HasPrefix(err.Error(), "yaml line ") {
+			t.Errorf("yamlToJSON(%q) error %q has no line number", src, err)
+		}
+	}
+}
+
+func TestParseCatalogYAML(t *testing.T) {
+	js := `{"package": "errcode", "codes": [
+		{"code": 40401, "name": "UserNotFound", "message": "user {id} not found", "params": {"id": "int64"},
+		 "http": 404, "grpc": "NotFound", "translations": {"zh-CN": "用户 {id} 不存在"}},
+		{"code": 50001, "name": "DBTimeout", "message": "database timeout", "grpc": 14, "retryable": true}]}`
+	yml := `
+package: errcode
+codes:
+  - code: 40401
+    name: UserNotFound
+    message: "user {id} not found"
+    params: {id: int64}
+    http: 404
+    grpc: NotFound
+    translations:
+      zh-CN: 用户 {id} 不存在
+  - code: 50001
+    name: DBTimeout
+    message: database timeout
+    grpc: 14
+    retryable: true
+`
+	want, err := parseCatalog("errors.json", []byte(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"errors.yaml", "errors.YML"} {
+		got, err := parseCatalog(name, []byte(yml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+	if _, err := parseCatalog("errors.yaml", []byte("package: x\ncodes:\n  - code: 1\n    nmae: Typo\n")); err == nil ||
+		!strings.Contains(err.Error(), "nmae") {
+		t.Errorf("unknown field in YAML: %v", err)
+	}
+}
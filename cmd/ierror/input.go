@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/RanFeng/ierror"
+)
+
+// entry 从日志中还原出的一条错误
+type entry struct {
+	layers []ierror.Layer
+	// source 错误在日志中的位置，形如 文件:行号
+	source string
+}
+
+// key 分组使用的键，只由各层的错误码和是否为外部错误计算，是命令行自己的键，与ierror.Fingerprint的值不同：
+// 没有Trace的Record和IError序列化的结构中没有函数名，
+// 只用各种输入中都有的信息，同一个错误无论以哪种形式记录都得到相同的键
+func (e entry) key() string {
+	codes := make([]ierror.Layer, len(e.layers))
+	for i, l := range e.layers {
+		codes[i] = ierror.Layer{Code: l.Code, Foreign: l.Foreign}
+	}
+	return ierror.LayersFingerprint(codes)
+}
+
+var (
+	// frameStart 函数行的开头，信息中有换行时函数行会延续到后面的行
+	frameStart = regexp.MustCompile(`^\S+ : \[`)
+	// locationLine 位置行，结束一层的信息
+	locationLine = regexp.MustCompile(`^\t.*:(\d+|sorry)(?: \S+)?$`)
+)
+
+// traceLine 判断一行是否属于默认格式的Trace输出
+func traceLine(line string) bool {
+	line = ierror.StripANSI(line)
+	return strings.HasPrefix(line, "\t") ||
+		strings.HasPrefix(line, "not.found : ") ||
+		strings.HasPrefix(line, "build : ") ||
+		strings.HasPrefix(line, "... ") ||
+		line == "breadcrumbs :" ||
+		frameStart.MatchString(line)
+}
+
+// messageStart 判断一行是否开始了一层的信息，信息在下一个位置行之前可能跨越多行
+func messageStart(line string) bool {
+	line = ierror.StripANSI(line)
+	return strings.HasPrefix(line, "not.found : ") || frameStart.MatchString(line)
+}
+
+// readEntries 依次读取各个文件中的错误，没有文件或文件名为 - 时读取标准输入
+// 每行一个JSON的错误（Record、FormatJSON或IError序列化的结构）和默认格式的Trace文本可以混在一起，
+// 其他行被忽略，无法解析的错误输出到标准错误后跳过
+func readEntries(files []string) ([]entry, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var out []entry
+	for _, name := range files {
+		var (
+			entries []entry
+			err     error
+		)
+		if name == "-" {
+			entries, err = scanEntries(os.Stdin, "<stdin>")
+		} else {
+			var f *os.File
+			if f, err = os.Open(name); err != nil {
+				return nil, err
+			}
+			entries, err = scanEntries(f, name)
+			f.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+	}
+	return out, nil
+}
+
+func scanEntries(r io.Reader, name string) ([]entry, error) {
+	var (
+		out   []entry
+		block []string
+		start int
+		// open 块中最后一层的信息还没有遇到位置行，其间的任何行都是信息的延续
+		open bool
+	)
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		src := fmt.Sprintf("%s:%d", name, start)
+		layers, err := ierror.ParseTrace(strings.Join(block, "\n"))
+		block, open = block[:0], false
+		if err != nil {
+			warn(src, err)
+			return
+		}
+		if len(layers) > 0 {
+			out = append(out, entry{layers: layers, source: src})
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "{") {
+			flush()
+			src := fmt.Sprintf("%s:%d", name, n)
+			e, ok, err := jsonEntry([]byte(trimmed))
+			if err != nil {
+				warn(src, err)
+			} else if ok {
+				e.source = src
+				out = append(out, e)
+			}
+			continue
+		}
+		// Trace以换行开头，空行和普通的日志行都是两条Trace之间的分隔，
+		// 多行信息（例如errors.Join）中的行除外
+		if !open && !traceLine(line) {
+			flush()
+			continue
+		}
+		if len(block) == 0 {
+			start = n
+		}
+		block = append(block, line)
+		if messageStart(line) {
+			open = true
+		} else if locationLine.MatchString(ierror.StripANSI(line)) {
+			open = false
+		}
+	}
+	flush()
+	return out, sc.Err()
+}
+
+// jsonRecord 兼容Record、FormatJSON和IError序列化后的各个字段
+type jsonRecord struct {
+	BuildID string          `json:"build_id"`
+	Trace   string          `json:"trace"`
+	Error   json.RawMessage `json:"error"`
+	Layers  []jsonLayer     `json:"layers"`
+	Err     json.RawMessage `json:"err"`
+	Code    int             `json:"code"`
+	Msg     string          `json:"msg"`
+}
+
+type jsonLayer struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Foreign bool   `json:"foreign"`
+	Frames  []struct {
+		Function string `json:"function"`
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Repeat   int    `json:"repeat"`
+	} `json:"frames"`
+	OmittedLayers int `json:"omitted_layers"`
+}
+
+// jsonEntry 解析一行JSON，不是错误的JSON返回ok为false
+func jsonEntry(b []byte) (e entry, ok bool, err error) {
+	var rec jsonRecord
+	if err = json.Unmarshal(b, &rec); err != nil {
+		return entry{}, false, err
+	}
+	switch {
+	case rec.BuildID != "" || strings.HasPrefix(rec.Trace, rawPrefix):
+		return entry{}, false, errors.New("raw trace, run ierror symbolize first")
+	case rec.Layers != nil:
+		// FormatJSON 最外层在前
+		for i := len(rec.Layers) - 1; i >= 0; i-- {
+			jl := rec.Layers[i]
+			if jl.OmittedLayers > 0 {
+				continue
+			}
+			l := ierror.Layer{Code: jl.Code, Msg: jl.Msg, Foreign: jl.Foreign}
+			for _, f := range jl.Frames {
+				for j := 0; j < f.Repeat || j == 0; j++ {
+					l.Frames = append(l.Frames, newFrame(f.Function, f.File, f.Line))
+				}
+			}
+			e.layers = append(e.layers, l)
+		}
+	case rec.Trace != "":
+		if e.layers, err = ierror.ParseTrace(rec.Trace); err != nil {
+			return entry{}, false, err
+		}
+	case rec.Error != nil:
+		if e.layers, err = errorLayers(rec.Error); err != nil {
+			return entry{}, false, err
+		}
+	case rec.Err != nil:
+		if e.layers, err = errorLayers(b); err != nil {
+			return entry{}, false, err
+		}
+	default:
+		return entry{}, false, nil
+	}
+	return e, len(e.layers) > 0, nil
+}
+
+// errorLayers 将IError序列化后的嵌套结构还原为各层，最内层在前，各层没有调用栈
+func errorLayers(b json.RawMessage) ([]ierror.Layer, error) {
+	var layers []ierror.Layer
+	for len(b) > 0 && string(b) != "null" {
+		var msg string
+		if json.Unmarshal(b, &msg) == nil {
+			layers = append(layers, ierror.Layer{Msg: msg, Foreign: true})
+			break
+		}
+		var rec jsonRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return nil, err
+		}
+		layers = append(layers, ierror.Layer{Code: rec.Code, Msg: rec.Msg})
+		b = rec.Err
+	}
+	for i, j := 0, len(layers)-1; i < j; i, j = i+1, j-1 {
+		layers[i], layers[j] = layers[j], layers[i]
+	}
+	return layers, nil
+}
+
+func newFrame(function, file string, line int) ierror.Frame {
+	return ierror.Frame{
+		Function: function,
+		Package:  ierror.FuncPackage(function),
+		File:     file,
+		Line:     line,
+	}
+}
+
+func warn(src string, err error) {
+	fmt.Fprintf(os.Stderr, "ierror: %s: %v\n", src, err)
+}
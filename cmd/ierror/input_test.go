@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/RanFeng/ierror"
+)
+
+func loadUser() error { return ierror.WrapIError(errors.New("sql: no rows"), 40401, "user not found") }
+
+func handle() error { return ierror.WrapIError(loadUser(), 50001, "handle request") }
+
+func TestGroupKeyMixedLog(t *testing.T) {
+	err := handle()
+	record := func(withTrace bool) string {
+		b, jerr := json.Marshal(ierror.NewRecord(err, withTrace))
+		if jerr != nil {
+			t.Fatal(jerr)
+		}
+		return string(b)
+	}
+	log := strings.Join([]string{
+		"2026-10-15 10:00:00 request failed",
+		record(false),
+		ierror.Trace(err),
+		"",
+		record(true),
+		ierror.TraceWith(err, ierror.TraceOptions{Format: ierror.FormatJSON}),
+		ierror.Trace(loadUser()),
+		"",
+	}, "\n")
+	entries, serr := scanEntries(strings.NewReader(log), "app.log")
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	want := entries[0].key()
+	for _, e := range entries[1:4] {
+		if k := e.key(); k != want {
+			t.Errorf("%s: key %s, want %s", e.source, k, want)
+		}
+	}
+	if entries[4].key() == want {
+		t.Errorf("%s: inner error grouped with the outer one", entries[4].source)
+	}
+}
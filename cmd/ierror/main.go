@@ -0,0 +1,234 @@
+// Command ierror 查看和转换日志中的错误
+//
+// 用法：
+//
+//	ierror pretty  [flags] [file ...]   以带颜色的默认格式输出日志中的错误
+//	ierror convert [flags] [file ...]   将日志中的错误转换为 -to 指定的格式
+//	ierror group   [flags] [file ...]   按错误码链聚合，输出出现次数最多的错误
+//	ierror symbolize -binary app [flags] [file ...]
+//	                                    使用二进制文件的符号表还原FormatRaw记录的调用栈
+//
+// 输入可以是默认格式的Trace文本，也可以是每行一个的JSON（Record、FormatJSON或IError序列化的结构），
+// 没有文件或文件名为 - 时读取标准输入
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/RanFeng/ierror"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmds := map[string]func([]string) error{
+		"pretty":    pretty,
+		"convert":   convert,
+		"group":     group,
+		"symbolize": symbolize,
+	}
+	cmd, ok := cmds[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "ierror:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: ierror <command> [flags] [file ...]
+
+commands:
+  pretty     print errors in the default trace format with color and trimmed paths
+  convert    convert errors to another format (-to text|compact|java|json|logfmt)
+  group      group errors by their chain of codes and print the top-N with counts
+  symbolize  symbolize raw traces (FormatRaw) with the symbol table of -binary
+
+run 'ierror <command> -h' for the flags of a command
+`)
+}
+
+// renderFlags pretty和convert共用的输出选项
+type renderFlags struct {
+	color string
+	paths string
+	root  string
+}
+
+func (r *renderFlags) register(fs *flag.FlagSet, color, paths string) {
+	fs.StringVar(&r.color, "color", color, "use ANSI colors: auto, always or never")
+	fs.StringVar(&r.paths, "paths", paths, "file paths: relative or absolute")
+	fs.StringVar(&r.root, "root", "", "module root on the build machine; frames under it are highlighted and trimmed")
+}
+
+func (r *renderFlags) options() (ierror.TraceOptions, error) {
+	opts := ierror.TraceOptions{ModuleRoot: r.root}
+	switch r.color {
+	case "auto":
+		opts.Color = ierror.ColorAuto
+	case "always":
+		opts.Color = ierror.ColorAlways
+	case "never":
+		opts.Color = ierror.ColorNever
+	default:
+		return opts, fmt.Errorf("unknown -color %q", r.color)
+	}
+	switch r.paths {
+	case "relative":
+		opts.Paths = ierror.PathRelative
+	case "absolute":
+		opts.Paths = ierror.PathAbsolute
+	default:
+		return opts, fmt.Errorf("unknown -paths %q", r.paths)
+	}
+	return opts, nil
+}
+
+// markInApp 日志中没有记录InApp，按-root和main包重新标记业务代码的帧
+func (r *renderFlags) markInApp(layers []ierror.Layer) {
+	root := ""
+	if r.root != "" {
+		root = strings.TrimSuffix(r.root, "/") + "/"
+	}
+	for i := range layers {
+		for j := range layers[i].Frames {
+			f := &layers[i].Frames[j]
+			f.InApp = f.Package == "main" || (root != "" && strings.HasPrefix(f.File, root))
+		}
+	}
+}
+
+var formats = map[string]ierror.TraceFormat{
+	"text":    ierror.FormatDefault,
+	"compact": ierror.FormatCompact,
+	"java":    ierror.FormatJava,
+	"json":    ierror.FormatJSON,
+	"logfmt":  ierror.FormatLogfmt,
+}
+
+func pretty(args []string) error {
+	fs := flag.NewFlagSet("pretty", flag.ExitOnError)
+	var rf renderFlags
+	rf.register(fs, "auto", "relative")
+	_ = fs.Parse(args)
+	opts, err := rf.options()
+	if err != nil {
+		return err
+	}
+	return render(os.Stdout, fs.Args(), &rf, opts)
+}
+
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	var rf renderFlags
+	rf.register(fs, "never", "absolute")
+	to := fs.String("to", "json", "output format: text, compact, java, json or logfmt")
+	_ = fs.Parse(args)
+	opts, err := rf.options()
+	if err != nil {
+		return err
+	}
+	format, ok := formats[*to]
+	if !ok {
+		return fmt.Errorf("unknown -to %q", *to)
+	}
+	opts.Format = format
+	return render(os.Stdout, fs.Args(), &rf, opts)
+}
+
+// render 按选项依次输出各个错误，每个错误之后换行
+func render(w io.Writer, files []string, rf *renderFlags, opts ierror.TraceOptions) error {
+	entries, err := readEntries(files)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		rf.markInApp(e.layers)
+		if err := ierror.WriteLayers(w, e.layers, opts); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// occurrence group中同一分组的错误
+type occurrence struct {
+	key   string
+	count int
+	first entry
+}
+
+func group(args []string) error {
+	fs := flag.NewFlagSet("group", flag.ExitOnError)
+	top := fs.Int("n", 10, "number of groups to print, 0 for all")
+	_ = fs.Parse(args)
+	entries, err := readEntries(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	// 分组的键是命令行自己的，与ierror.Fingerprint的值不同，参见entry.key
+	index := map[string]*occurrence{}
+	var groups []*occurrence
+	for _, e := range entries {
+		key := e.key()
+		o, ok := index[key]
+		if !ok {
+			o = &occurrence{key: key, first: e}
+			index[key] = o
+			groups = append(groups, o)
+		}
+		o.count++
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+	if *top > 0 && len(groups) > *top {
+		groups = groups[:*top]
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNT\tCODE\tGROUP KEY\tERROR\tWHERE\tFIRST SEEN")
+	for _, o := range groups {
+		code, msg, where := summary(o.first.layers)
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\n", o.count, code, o.key, msg, where, o.first.source)
+	}
+	return tw.Flush()
+}
+
+// summary 返回最外层IError的错误码、整条错误信息和最外层的创建位置
+func summary(layers []ierror.Layer) (code int, msg, where string) {
+	code = ierror.ErrUnknown
+	msgs := make([]string, 0, len(layers))
+	for _, l := range layers {
+		msgs = append(msgs, l.Msg)
+		if !l.Foreign {
+			code = l.Code
+			where = ""
+			if len(l.Frames) > 0 {
+				where = l.Frames[0].ShortFunction()
+			}
+		}
+	}
+	// 多行的信息合并为一行，避免打乱表格
+	msg = strings.Join(strings.Fields(strings.Join(msgs, ": ")), " ")
+	if r := []rune(msg); len(r) > 80 {
+		msg = string(r[:77]) + "..."
+	}
+	if where == "" {
+		where = "-"
+	}
+	return code, msg, where
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"debug/gosym"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/RanFeng/ierror"
+)
+
+// symbolizer 使用二进制文件的符号表还原RawTrace
+type symbolizer struct {
+	buildID string
+	table   *gosym.Table
+	// anchor RawAnchor在二进制文件中的入口地址
+	anchor uint64
+}
+
+// newSymbolizer 读取ELF可执行文件的 .gopclntab，去掉符号表（-s）的文件同样可用
+func newSymbolizer(path string) (*symbolizer, error) {
+	buildID, err := ierror.ReadBuildID(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: only ELF executables are supported: %w", path, err)
+	}
+	defer f.Close()
+	pcln := f.Section(".gopclntab")
+	text := f.Section(".text")
+	if pcln == nil || text == nil {
+		return nil, fmt.Errorf("%s: no Go line table", path)
+	}
+	data, err := pcln.Data()
+	if err != nil {
+		return nil, err
+	}
+	// 开启cgo时runtime.text与 .text 段的起始地址不同，符号表被去掉时只能使用段地址
+	start := text.Addr
+	if syms, err := f.Symbols(); err == nil {
+		for _, s := range syms {
+			if s.Name == "runtime.text" {
+				start = s.Value
+				break
+			}
+		}
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, start))
+	if err != nil {
+		return nil, err
+	}
+	s := &symbolizer{buildID: buildID, table: table}
+	if fn := table.LookupFunc(ierror.RawAnchor); fn != nil {
+		s.anchor = fn.Entry
+	}
+	return s, nil
+}
+
+// layers 将RawTrace还原为各层
+// debug/gosym不展开内联，被内联的函数显示为其所在的外层函数，文件和行号仍然准确
+func (s *symbolizer) layers(raw *ierror.RawTrace) ([]ierror.Layer, error) {
+	if raw.BuildID != s.buildID {
+		return nil, fmt.Errorf("build ID mismatch: trace %q, binary %q", raw.BuildID, s.buildID)
+	}
+	// 地址随机化（PIE）时，记录时的地址与符号表中的地址相差一个固定的偏移
+	var slide uint64
+	if s.anchor != 0 && raw.Anchor != 0 {
+		slide = raw.Anchor - s.anchor
+	}
+	out := make([]ierror.Layer, 0, len(raw.Layers))
+	for _, rl := range raw.Layers {
+		l := ierror.Layer{Code: rl.Code, Msg: rl.Msg, Foreign: rl.Foreign}
+		for _, pc := range rl.PCs {
+			// 调用栈中记录的是返回地址，减一后落在调用指令上
+			file, line, fn := s.table.PCToLine(pc - slide - 1)
+			name := "unknown"
+			if fn != nil {
+				name = fn.Name
+			}
+			l.Frames = append(l.Frames, newFrame(name, file, line))
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func symbolize(args []string) error {
+	fs := flag.NewFlagSet("symbolize", flag.ExitOnError)
+	binary := fs.String("binary", "", "the executable that produced the raw traces (required)")
+	force := fs.Bool("force", false, "symbolize even if the build IDs do not match")
+	to := fs.String("to", "text", "output format: text, compact, java, json or logfmt")
+	var rf renderFlags
+	rf.register(fs, "auto", "relative")
+	_ = fs.Parse(args)
+	if *binary == "" {
+		return errors.New("symbolize: -binary is required")
+	}
+	opts, err := rf.options()
+	if err != nil {
+		return err
+	}
+	format, ok := formats[*to]
+	if !ok {
+		return fmt.Errorf("unknown -to %q", *to)
+	}
+	opts.Format = format
+	s, err := newSymbolizer(*binary)
+	if err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	w := bufio.NewWriter(os.Stdout)
+	for _, name := range files {
+		if name == "-" {
+			err = s.rewrite(w, os.Stdin, "<stdin>", &rf, opts, *force)
+		} else {
+			var f *os.File
+			if f, err = os.Open(name); err != nil {
+				return err
+			}
+			err = s.rewrite(w, f, name, &rf, opts, *force)
+			f.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+const rawPrefix = `{"build_id":`
+
+// rewrite 原样输出每一行，行中的RawTrace和trace字段为RawTrace的Record被替换为符号化后的结果
+func (s *symbolizer) rewrite(w io.Writer, r io.Reader, name string, rf *renderFlags, opts ierror.TraceOptions, force bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		raw, prefix, suffix := findRaw(line)
+		if raw == nil {
+			fmt.Fprintln(w, line)
+			continue
+		}
+		if force {
+			raw.BuildID = s.buildID
+		}
+		layers, err := s.layers(raw)
+		if err != nil {
+			warn(fmt.Sprintf("%s:%d", name, n), err)
+			fmt.Fprintln(w, line)
+			continue
+		}
+		rf.markInApp(layers)
+		io.WriteString(w, prefix)
+		if err := ierror.WriteLayers(w, layers, opts); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, suffix)
+	}
+	return sc.Err()
+}
+
+// findRaw 在一行中查找RawTrace，返回它和前后的内容
+func findRaw(line string) (raw *ierror.RawTrace, prefix, suffix string) {
+	if i := strings.Index(line, rawPrefix); i >= 0 {
+		dec := json.NewDecoder(strings.NewReader(line[i:]))
+		var rt ierror.RawTrace
+		if dec.Decode(&rt) == nil {
+			return &rt, line[:i], line[i+int(dec.InputOffset()):]
+		}
+	}
+	// Record的trace字段为FormatRaw输出时，整行替换为符号化后的结果
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var rec ierror.Record
+		if json.Unmarshal([]byte(line), &rec) == nil && strings.HasPrefix(rec.Trace, rawPrefix) {
+			var rt ierror.RawTrace
+			if json.Unmarshal([]byte(rec.Trace), &rt) == nil {
+				return &rt, "", ""
+			}
+		}
+	}
+	return nil, "", ""
+}
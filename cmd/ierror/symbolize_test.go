@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/RanFeng/ierror"
+)
+
+func TestFindRaw(t *testing.T) {
+	raw := ierror.RawOf(handle())
+	b, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := json.Marshal(ierror.Record{Msg: "x", Trace: string(b)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name           string
+		line           string
+		found          bool
+		prefix, suffix string
+	}{
+		{name: "whole line", line: string(b), found: true},
+		{name: "prefix and suffix", line: "10:00:00 ERROR " + string(b) + " req=42", found: true,
+			prefix: "10:00:00 ERROR ", suffix: " req=42"},
+		{name: "suffix with braces", line: "err=" + string(b) + "} {", found: true, prefix: "err=", suffix: "} {"},
+		{name: "record", line: string(rec), found: true},
+		{name: "truncated", line: "err=" + string(b[:len(b)/2])},
+		{name: "plain", line: "10:00:00 INFO started"},
+		{name: "other json", line: `{"level":"info"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, prefix, suffix := findRaw(tt.line)
+			if (got != nil) != tt.found {
+				t.Fatalf("found %t, want %t", got != nil, tt.found)
+			}
+			if got == nil {
+				return
+			}
+			if prefix != tt.prefix || suffix != tt.suffix {
+				t.Errorf("prefix %q suffix %q, want %q %q", prefix, suffix, tt.prefix, tt.suffix)
+			}
+			if got.BuildID != raw.BuildID || got.Anchor != raw.Anchor || len(got.Layers) != len(raw.Layers) {
+				t.Errorf("got %+v, want %+v", got, raw)
+			}
+		})
+	}
+}
+
+// TestSymbolizeSelf 用测试程序自己的符号表还原RawTrace，与Layers得到的函数和行号一致
+func TestSymbolizeSelf(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := newSymbolizer(exe)
+	if err != nil {
+		t.Skip(err)
+	}
+	e := handle()
+	raw := ierror.RawOf(e)
+	if raw.BuildID == "" {
+		t.Skip("no build ID")
+	}
+	got, err := s.layers(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ierror.Layers(e)
+	if len(got) != len(want) {
+		t.Fatalf("%d layers, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if len(got[i].Frames) != len(want[i].Frames) {
+			t.Errorf("layer %d: %d frames, want %d", i, len(got[i].Frames), len(want[i].Frames))
+			continue
+		}
+		for j, f := range want[i].Frames {
+			if g := got[i].Frames[j]; g.Function != f.Function || g.Line != f.Line {
+				t.Errorf("layer %d frame %d: %s:%d, want %s:%d", i, j, g.Function, g.Line, f.Function, f.Line)
+			}
+		}
+	}
+	raw.BuildID = "other"
+	if _, err := s.layers(raw); err == nil {
+		t.Error("mismatched build ID accepted")
+	}
+}
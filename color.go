@@ -0,0 +1,73 @@
+package ierror
+
+import (
+	"io"
+	"os"
+)
+
+// ColorMode Trace是否使用ANSI颜色
+type ColorMode int
+
+const (
+	// ColorNever 不使用颜色（默认）
+	ColorNever ColorMode = iota
+	// ColorAuto 输出到终端且NO_COLOR环境变量为空时使用颜色
+	// 输出目标不是*os.File时（例如TraceWith返回字符串、bufio.Writer）不使用颜色
+	ColorAuto
+	// ColorAlways 始终使用颜色
+	ColorAlways
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiRed   = "\x1b[31m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// colorEnabled 根据选项和输出目标决定是否使用颜色
+func (o TraceOptions) colorEnabled(w io.Writer) bool {
+	switch o.Color {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		// 按 no-color.org 的约定，NO_COLOR为空字符串时不生效
+		if os.Getenv("NO_COLOR") != "" {
+			return false
+		}
+		f, ok := w.(*os.File)
+		return ok && isTerminal(f)
+	}
+	return false
+}
+
+// isTerminal 判断文件是否为字符设备（终端）
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// palette 输出时使用的颜色，关闭颜色时原样返回
+type palette bool
+
+func (p palette) paint(code, s string) string {
+	if !p || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// code 错误码，红色
+func (p palette) code(s string) string { return p.paint(ansiRed, s) }
+
+// msg 错误信息，加粗
+func (p palette) msg(s string) string { return p.paint(ansiBold, s) }
+
+// frame 业务代码的帧高亮，依赖库的帧变暗
+func (p palette) frame(s string, inApp bool) string {
+	if inApp {
+		return p.paint(ansiBold+ansiCyan, s)
+	}
+	return p.paint(ansiDim, s)
+}
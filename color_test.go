@@ -0,0 +1,39 @@
+package ierror
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestColorAutoNonFile(t *testing.T) {
+	err := WrapIError(NewIError(1, "inner"), 2, "outer")
+	if s := TraceWith(err, TraceOptions{Color: ColorAuto}); strings.Contains(s, "\x1b[") {
+		t.Errorf("ColorAuto to a strings.Builder used colors:\n%q", s)
+	}
+	if s := TraceWith(err, TraceOptions{Color: ColorAlways}); !strings.Contains(s, ansiRed) {
+		t.Errorf("ColorAlways did not use colors:\n%q", s)
+	}
+}
+
+func TestColorAutoNoColor(t *testing.T) {
+	// isTerminal只检查字符设备，/dev/null可以代替终端
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	if !isTerminal(f) {
+		t.Skip("os.DevNull is not a character device")
+	}
+	opts := TraceOptions{Color: ColorAuto}
+	for _, tt := range []struct {
+		value string
+		want  bool
+	}{{"", true}, {"1", false}, {"false", false}} {
+		t.Setenv("NO_COLOR", tt.value)
+		if got := opts.colorEnabled(f); got != tt.want {
+			t.Errorf("NO_COLOR=%q: colors %t, want %t", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package ierror
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DebugPath 调试页面默认挂载的路径
+const DebugPath = "/debug/ierrors"
+
+// defaultRecentSize 最近错误环形缓冲区的默认容量
+const defaultRecentSize = 512
+
+// reported 一次被上报的错误
+type reported struct {
+	err error
+	at  time.Time
+}
+
+// ring 有界、并发安全的环形缓冲区，写满后覆盖最旧的记录
+type ring struct {
+	mu   sync.Mutex
+	buf  []reported
+	next int
+	full bool
+}
+
+func newRing(size int) *ring {
+	if size <= 0 {
+		size = defaultRecentSize
+	}
+	return &ring{buf: make([]reported, size)}
+}
+
+func (r *ring) add(err error, at time.Time) {
+	r.mu.Lock()
+	r.buf[r.next] = reported{err: err, at: at}
+	r.next++
+	if r.next == len(r.buf) {
+		r.next, r.full = 0, true
+	}
+	r.mu.Unlock()
+}
+
+// snapshot 按时间先后返回缓冲区中的所有记录
+func (r *ring) snapshot() []reported {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]reported(nil), r.buf[:r.next]...)
+	}
+	out := make([]reported, 0, len(r.buf))
+	out = append(out, r.buf[r.next:]...)
+	return append(out, r.buf[:r.next]...)
+}
+
+var (
+	recentMu sync.RWMutex
+	recent   = newRing(defaultRecentSize)
+)
+
+// SetRecentSize 重新设置最近错误缓冲区的容量，已有的记录会被清空
+func SetRecentSize(size int) {
+	recentMu.Lock()
+	recent = newRing(size)
+	recentMu.Unlock()
+}
+
+// Report 将错误记录到进程内的最近错误缓冲区，供调试页面展示
+func Report(err error) {
+	if err == nil {
+		return
+	}
+	traceReported(context.Background(), err)
+	recentMu.RLock()
+	r := recent
+	recentMu.RUnlock()
+	r.add(err, time.Now())
+}
+
+// ErrorGroup 按指纹聚合后的一组错误
+type ErrorGroup struct {
+	Fingerprint string    `json:"fingerprint"`
+	Code        int32     `json:"code"`
+	Msg         string    `json:"msg"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Trace       string    `json:"trace"`
+
+	sample error
+}
+
+// RecentErrors 返回缓冲区中按指纹聚合的错误，最近出现的排在前面
+func RecentErrors() []ErrorGroup {
+	recentMu.RLock()
+	r := recent
+	recentMu.RUnlock()
+
+	groups := map[string]*ErrorGroup{}
+	for _, rec := range r.snapshot() {
+		key := Fingerprint(rec.err)
+		g, ok := groups[key]
+		if !ok {
+			g = &ErrorGroup{
+				Fingerprint: key,
+				FirstSeen:   rec.at,
+				Trace:       Trace(rec.err),
+				sample:      rec.err,
+			}
+			groups[key] = g
+		}
+		g.Count++
+		g.LastSeen = rec.at
+		g.Code = GetErrorCode(rec.err)
+		g.Msg = rec.err.Error()
+	}
+	out := make([]ErrorGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].LastSeen.After(out[j].LastSeen)
+	})
+	return out
+}
+
+// DebugHandler 返回展示最近错误的http.Handler
+// 默认输出HTML页面，请求带上 ?format=json 时输出JSON，?format=dot 时输出每组样本错误的DOT图
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(serveDebug)
+}
+
+// RegisterDebugHandler 将调试页面挂载到mux的DebugPath上，profile挂载到ProfilePath上
+// mux为nil时使用http.DefaultServeMux
+func RegisterDebugHandler(mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(DebugPath, DebugHandler())
+	mux.Handle(ProfilePath, ProfileHandler())
+}
+
+// Middleware 边界中间件：捕获handler中以error为值的panic并上报，随后继续panic
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if err, ok := v.(error); ok {
+					Report(err)
+				}
+				panic(v)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serveDebug(w http.ResponseWriter, r *http.Request) {
+	groups := RecentErrors()
+	switch r.FormValue("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(groups)
+		return
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		for _, g := range groups {
+			_ = WriteDOT(w, g.sample)
+		}
+		return
+	}
+	type row struct {
+		ErrorGroup
+		Report template.HTML
+	}
+	rows := make([]row, 0, len(groups))
+	for _, g := range groups {
+		// RenderHTML的输出已经转义过
+		rows = append(rows, row{ErrorGroup: g, Report: template.HTML(RenderHTML(g.sample))})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = debugTmpl.Execute(w, rows)
+}
+
+var debugTmpl = template.Must(template.New("ierrors").Parse(`<!DOCTYPE html>
+<html>
+<head><title>/debug/ierrors</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; vertical-align: top; text-align: left; }
+.ierror { font-size: 12px; }
+.ierror-lib { color: #888; }
+</style>
+</head>
+<body>
+<h1>/debug/ierrors</h1>
+<p>{{len .}} groups. <a href="?format=json">json</a> <a href="?format=dot">dot</a></p>
+<table>
+<tr><th>code</th><th>msg</th><th>count</th><th>first seen</th><th>last seen</th><th>trace</th></tr>
+{{range .}}<tr>
+<td>{{.Code}}</td>
+<td>{{.Msg}}</td>
+<td>{{.Count}}</td>
+<td>{{.FirstSeen.Format "2006-01-02 15:04:05.000"}}</td>
+<td>{{.LastSeen.Format "2006-01-02 15:04:05.000"}}</td>
+<td><details><summary>{{.Fingerprint}}</summary>{{.Report}}</details></td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,77 @@
+package ierror
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func debugErrA() error { return NewIError(11, "a failed") }
+
+func debugErrB() error { return WrapIError(debugErrA(), 12, "b <failed>") }
+
+func debugErrC() error { return NewIError(13, "c failed") }
+
+func TestDebugHandler(t *testing.T) {
+	SetRecentSize(3)
+	defer SetRecentSize(defaultRecentSize)
+
+	a, b, c := debugErrA(), debugErrB(), debugErrC()
+	// 容量为3，写满后覆盖最旧的记录：a被覆盖，只剩下b和两次c
+	for _, err := range []error{a, a, b, c, debugErrC()} {
+		Report(err)
+	}
+	Report(nil)
+
+	get := func(query string) (string, string) {
+		rec := httptest.NewRecorder()
+		DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", DebugPath+query, nil))
+		if rec.Code != 200 {
+			t.Fatalf("%s: status %d", query, rec.Code)
+		}
+		return rec.Header().Get("Content-Type"), rec.Body.String()
+	}
+
+	ct, body := get("?format=json")
+	if !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("json: content type %q", ct)
+	}
+	var groups []ErrorGroup
+	if err := json.Unmarshal([]byte(body), &groups); err != nil {
+		t.Fatalf("json: %v\n%s", err, body)
+	}
+	counts := map[string]int{}
+	for _, g := range groups {
+		counts[g.Fingerprint] = g.Count
+		if g.Trace == "" || g.LastSeen.Before(g.FirstSeen) {
+			t.Errorf("json: incomplete group %+v", g)
+		}
+	}
+	want := map[string]int{Fingerprint(b): 1, Fingerprint(c): 2}
+	if len(counts) != len(want) || counts[Fingerprint(b)] != 1 || counts[Fingerprint(c)] != 2 {
+		t.Errorf("json: groups %v, want %v", counts, want)
+	}
+	if len(groups) == 2 && groups[0].LastSeen.After(groups[1].LastSeen) && groups[0].Fingerprint != Fingerprint(c) {
+		t.Errorf("json: most recent group is %q, want %q", groups[0].Msg, c.Error())
+	}
+
+	ct, body = get("")
+	if !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("html: content type %q", ct)
+	}
+	if !strings.Contains(body, "2 groups.") || !strings.Contains(body, Fingerprint(c)) {
+		t.Errorf("html: groups missing in\n%s", body)
+	}
+	if strings.Contains(body, "b <failed>") || !strings.Contains(body, "b &lt;failed&gt;") {
+		t.Errorf("html: message not escaped in\n%s", body)
+	}
+
+	ct, body = get("?format=dot")
+	if !strings.HasPrefix(ct, "text/vnd.graphviz") {
+		t.Errorf("dot: content type %q", ct)
+	}
+	if n := strings.Count(body, "digraph ierror {"); n != 2 {
+		t.Errorf("dot: %d graphs, want 2\n%s", n, body)
+	}
+}
@@ -0,0 +1,97 @@
+package ierror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DOT 将错误图渲染为Graphviz DOT格式
+// 每个IError和其他error各是一个节点，IError节点展示错误码、信息和创建位置；
+// 单个内层错误连一条 wrap 边，errors.Join等 Unwrap() []error 的错误为每个内层错误连一条 join 边
+func DOT(err error) string {
+	var sb strings.Builder
+	_ = WriteDOT(&sb, err)
+	return sb.String()
+}
+
+// WriteDOT 与DOT相同，直接写入w
+func WriteDOT(w io.Writer, err error) error {
+	g := &dotGraph{ids: map[uintptr]string{}}
+	g.lines = append(g.lines, "digraph ierror {", "\tnode [shape=box, fontname=monospace];")
+	if err != nil {
+		g.node(err)
+	}
+	g.lines = append(g.lines, "}")
+	_, e := io.WriteString(w, strings.Join(g.lines, "\n")+"\n")
+	return e
+}
+
+type dotGraph struct {
+	lines []string
+	ids   map[uintptr]string
+	next  int
+}
+
+// node 输出err及其内层的节点和边，返回err的节点名
+// 指针类型的错误按地址去重，同一个错误被多处引用时只输出一个节点
+func (g *dotGraph) node(err error) string {
+	var ptr uintptr
+	if v := reflect.ValueOf(err); v.Kind() == reflect.Ptr {
+		ptr = v.Pointer()
+		if id, ok := g.ids[ptr]; ok {
+			return id
+		}
+	}
+	id := "e" + strconv.Itoa(g.next)
+	g.next++
+	if ptr != 0 {
+		g.ids[ptr] = id
+	}
+
+	if ge, ok := err.(*IError); ok {
+		label := ""
+		if ge.Code != 0 {
+			label = fmt.Sprintf("code: %d\n", ge.Code)
+		}
+		label += ge.Msg
+		if frames := layerFrames(ge); len(frames) > 0 {
+			f := frames[0]
+			label += fmt.Sprintf("\n%s\n%s:%d", f.Function, f.File, f.Line)
+		}
+		g.lines = append(g.lines, fmt.Sprintf("\t%s [label=%s];", id, dotQuote(label)))
+		if ge.Err != nil {
+			g.edge(id, g.node(ge.Err), "wrap")
+		}
+		return id
+	}
+
+	g.lines = append(g.lines, fmt.Sprintf("\t%s [label=%s, style=dashed];",
+		id, dotQuote(fmt.Sprintf("%T\n%s", err, err.Error()))))
+	switch x := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, inner := range x.Unwrap() {
+			if inner != nil {
+				g.edge(id, g.node(inner), "join")
+			}
+		}
+	default:
+		if inner := errors.Unwrap(err); inner != nil {
+			g.edge(id, g.node(inner), "wrap")
+		}
+	}
+	return id
+}
+
+func (g *dotGraph) edge(from, to, label string) {
+	g.lines = append(g.lines, fmt.Sprintf("\t%s -> %s [label=%q];", from, to, label))
+}
+
+// dotQuote 转义为DOT的字符串，换行使用左对齐的 \l
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", `\l`).Replace(s)
+	return `"` + s + `\l"`
+}
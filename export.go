@@ -0,0 +1,176 @@
+package ierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// codeName 导出时使用的名称，没有名称的错误码使用 Code<错误码>
+func codeName(info CodeInfo) string {
+	if info.Name != "" {
+		return info.Name
+	}
+	return "Code" + strings.ReplaceAll(strconv.Itoa(info.Code), "-", "_")
+}
+
+// jsString 返回JavaScript字符串字面量，不转义HTML字符
+func jsString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// WriteTypeScript 将错误码导出为TypeScript定义，codes通常为Codes()或错误码目录中的内容
+// 输出包含错误码的enum、名称的联合类型、各错误码的元信息，以及problem+json响应体的接口
+func WriteTypeScript(w io.Writer, codes []CodeInfo) error {
+	var sb strings.Builder
+	sb.WriteString("// Code generated by ierror. DO NOT EDIT.\n\nexport enum ErrorCode {\n")
+	for _, c := range codes {
+		fmt.Fprintf(&sb, "  /** %s */\n  %s = %d,\n", strings.ReplaceAll(c.Message, "*/", "*\\/"), codeName(c), c.Code)
+	}
+	sb.WriteString("}\n\nexport type ErrorCodeName =")
+	for _, c := range codes {
+		sb.WriteString("\n  | " + jsString(codeName(c)))
+	}
+	if len(codes) == 0 {
+		sb.WriteString(" never")
+	}
+	sb.WriteString(`;
+
+export interface ErrorCodeInfo {
+  code: ErrorCode;
+  name: ErrorCodeName;
+  /** 消息模板，参数以 {name} 表示 */
+  message: string;
+  family?: string;
+  http?: number;
+  grpc?: number;
+  retryable: boolean;
+  translations: Record<string, string>;
+}
+
+export const errorCodes: Record<ErrorCode, ErrorCodeInfo> = {
+`)
+	for _, c := range codes {
+		name := codeName(c)
+		fmt.Fprintf(&sb, "  [ErrorCode.%s]: {\n    code: ErrorCode.%s,\n    name: %s,\n    message: %s,\n",
+			name, name, jsString(name), jsString(c.Message))
+		if c.Family != "" {
+			fmt.Fprintf(&sb, "    family: %s,\n", jsString(c.Family))
+		}
+		if c.HTTP != 0 {
+			fmt.Fprintf(&sb, "    http: %d,\n", c.HTTP)
+		}
+		if c.GRPC != 0 {
+			fmt.Fprintf(&sb, "    grpc: %d,\n", c.GRPC)
+		}
+		fmt.Fprintf(&sb, "    retryable: %t,\n    translations: {", c.Retryable)
+		langs := make([]string, 0, len(c.Translations))
+		for lang := range c.Translations {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for i, lang := range langs {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			fmt.Fprintf(&sb, " %s: %s", jsString(lang), jsString(c.Translations[lang]))
+		}
+		if len(langs) > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString("},\n  },\n")
+	}
+	sb.WriteString(`};
+
+/** problem+json（RFC 9457）错误响应体 */
+export interface Problem {
+  type?: string;
+  title: string;
+  status?: number;
+  detail?: string;
+  instance?: string;
+  code: ErrorCode;
+  retryable?: boolean;
+}
+`)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteOpenAPI 将错误码导出为OpenAPI 3的components片段（JSON），codes通常为Codes()或错误码目录中的内容
+// schemas中的ErrorCode为所有错误码的枚举，Problem为problem+json（RFC 9457）的响应体，带有code扩展字段；
+// responses中为每个HTTP状态码生成一个ErrorXXX响应，其code只能取映射到该状态码的错误码
+func WriteOpenAPI(w io.Writer, codes []CodeInfo) error {
+	type object = map[string]interface{}
+	enum := make([]int, 0, len(codes))
+	names := make([]string, 0, len(codes))
+	descs := make([]string, 0, len(codes))
+	byStatus := map[int][]int{}
+	for _, c := range codes {
+		enum = append(enum, c.Code)
+		names = append(names, codeName(c))
+		descs = append(descs, c.Message)
+		if c.HTTP != 0 {
+			byStatus[c.HTTP] = append(byStatus[c.HTTP], c.Code)
+		}
+	}
+	problem := object{
+		"type": "object",
+		"properties": object{
+			"type":      object{"type": "string", "format": "uri-reference", "default": "about:blank"},
+			"title":     object{"type": "string"},
+			"status":    object{"type": "integer"},
+			"detail":    object{"type": "string"},
+			"instance":  object{"type": "string", "format": "uri-reference"},
+			"code":      object{"$ref": "#/components/schemas/ErrorCode"},
+			"retryable": object{"type": "boolean"},
+		},
+		"required": []string{"title", "code"},
+	}
+	responses := object{}
+	for status, inStatus := range byStatus {
+		responses["Error"+strconv.Itoa(status)] = object{
+			"description": fmt.Sprintf("problem+json error response with HTTP status %d", status),
+			"content": object{
+				"application/problem+json": object{
+					"schema": object{
+						"allOf": []object{
+							{"$ref": "#/components/schemas/Problem"},
+							{"type": "object", "properties": object{
+								"status": object{"type": "integer", "enum": []int{status}},
+								"code":   object{"type": "integer", "enum": inStatus},
+							}},
+						},
+					},
+				},
+			},
+		}
+	}
+	doc := object{
+		"components": object{
+			"schemas": object{
+				"ErrorCode": object{
+					"type":                "integer",
+					"enum":                enum,
+					"x-enum-varnames":     names,
+					"x-enum-descriptions": descs,
+					"description":         "error codes registered with ierror",
+				},
+				"Problem": problem,
+			},
+			"responses": responses,
+		},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,87 @@
+package ierror
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FrameFilter Trace中帧的过滤和折叠规则，零值表示不过滤
+// 每一层的第一帧（错误被创建的位置）始终保留
+type FrameFilter struct {
+	// Packages 隐藏包路径以这些前缀开头的帧
+	Packages []string
+	// Patterns 隐藏函数名匹配这些正则的帧，例如中间件
+	Patterns []*regexp.Regexp
+	// Stdlib 隐藏标准库的帧，例如 runtime.goexit、net/http.(*conn).serve、testing.tRunner
+	Stdlib bool
+	// InAppOnly 只保留主模块（和main包）的帧
+	InAppOnly bool
+	// StopAt 遇到函数名等于其中任一项的帧（例如 main.main 或HTTP handler）后，不再输出更外层的帧
+	StopAt []string
+	// Collapse 将连续重复的帧（递归调用）折叠为一帧，并标注 xN
+	Collapse bool
+}
+
+// StdlibFilter 隐藏标准库帧的过滤规则
+var StdlibFilter = FrameFilter{Stdlib: true, Collapse: true}
+
+// filteredFrame 过滤后的一帧，Repeat为折叠的次数
+type filteredFrame struct {
+	Frame
+	Repeat int
+}
+
+func (ff FrameFilter) hide(f Frame) bool {
+	if ff.InAppOnly && !f.InApp {
+		return true
+	}
+	if ff.Stdlib && stdlibPackage(f.Package) {
+		return true
+	}
+	for _, p := range ff.Packages {
+		if f.Package == p || strings.HasPrefix(f.Package, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	for _, re := range ff.Patterns {
+		if re.MatchString(f.Function) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ff FrameFilter) stop(f Frame) bool {
+	for _, fn := range ff.StopAt {
+		if f.Function == fn {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 按规则过滤一层的帧
+func (ff FrameFilter) apply(frames []Frame) []filteredFrame {
+	out := make([]filteredFrame, 0, len(frames))
+	// adjacent 上一帧被保留，中间隔着隐藏帧的相同函数不折叠
+	adjacent := false
+	for i, f := range frames {
+		if i > 0 && ff.hide(f) {
+			if ff.stop(f) {
+				break
+			}
+			adjacent = false
+			continue
+		}
+		if n := len(out); ff.Collapse && adjacent && out[n-1].Function == f.Function {
+			out[n-1].Repeat++
+		} else {
+			out = append(out, filteredFrame{Frame: f, Repeat: 1})
+		}
+		adjacent = true
+		if ff.stop(f) {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package ierror
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFrameFilterCollapse(t *testing.T) {
+	frames := func(fns ...string) []Frame {
+		out := make([]Frame, len(fns))
+		for i, fn := range fns {
+			out[i] = Frame{Function: fn, Package: FuncPackage(fn)}
+		}
+		return out
+	}
+	ff := FrameFilter{Patterns: []*regexp.Regexp{regexp.MustCompile(`^mw\.`)}, Collapse: true}
+	for _, tt := range []struct {
+		frames []Frame
+		want   string
+	}{
+		{frames("app.a", "app.b", "app.b", "app.b", "app.c"), "app.a app.b x3 app.c"},
+		// 隐藏帧两侧的相同函数不是连续的调用，不折叠
+		{frames("app.a", "app.b", "mw.log", "app.b", "app.c"), "app.a app.b app.b app.c"},
+		{frames("app.a", "app.a", "mw.log", "app.a", "app.a"), "app.a x2 app.a x2"},
+		// 第一帧始终保留
+		{frames("mw.log", "mw.log", "app.a"), "mw.log app.a"},
+	} {
+		var parts []string
+		for _, f := range ff.apply(tt.frames) {
+			s := f.Function
+			if f.Repeat > 1 {
+				s += fmt.Sprintf(" x%d", f.Repeat)
+			}
+			parts = append(parts, s)
+		}
+		if got := strings.Join(parts, " "); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package ierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"runtime"
+	"time"
+)
+
+// Fingerprint 计算错误的稳定指纹，用于跨版本聚合同一类错误
+// 指纹由链上每一层的错误码和创建位置的函数名决定，
+// 不包含行号和消息内容，因此改动代码行或消息中的变量不会影响聚合
+// 非IError的层使用其类型名参与计算，err为nil时返回空字符串
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	h := fnv.New64a()
+	for e := err; e != nil; {
+		if ge, ok := e.(*IError); ok {
+			_, _ = fmt.Fprintf(h, "%d@%s;", ge.Code, creationFunc(ge))
+			e = ge.Err
+			continue
+		}
+		_, _ = fmt.Fprintf(h, "%T;", e)
+		e = errors.Unwrap(e)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// LayersFingerprint 根据结构化的各层计算指纹，规则与Fingerprint相同
+// 各层中没有非IError的类型信息，这些层统一以 error 参与计算，
+// 因此只有各层都是IError且Function为完整函数名时结果才与Fingerprint一致
+func LayersFingerprint(layers []Layer) string {
+	if len(layers) == 0 {
+		return ""
+	}
+	h := fnv.New64a()
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		if l.Foreign {
+			_, _ = io.WriteString(h, "error;")
+			continue
+		}
+		fn := ""
+		if len(l.Frames) > 0 {
+			fn = l.Frames[0].Function
+		}
+		_, _ = fmt.Fprintf(h, "%d@%s;", l.Code, fn)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// creationFunc 返回IError被创建时所在的函数名
+func creationFunc(ge *IError) string {
+	if len(ge.pc) == 0 {
+		return ""
+	}
+	f, _ := runtime.CallersFrames(ge.pc[:1]).Next()
+	return f.Function
+}
+
+// jsonIError IError序列化后的结构
+type jsonIError struct {
+	Err         json.RawMessage `json:"err"`
+	Code        int             `json:"code"`
+	Msg         string          `json:"msg"`
+	Time        *time.Time      `json:"time,omitempty"`
+	Goroutine   uint64          `json:"goroutine,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Breadcrumbs []Crumb         `json:"breadcrumbs,omitempty"`
+	Build       *BuildInfo      `json:"build,omitempty"`
+}
+
+// MarshalJSON 序列化IError，内层IError嵌套输出，其他error输出Error()的内容
+// 只有最外层带有fingerprint、breadcrumbs和build字段
+// slog使用LogValue（需要go1.21），参见slog.go
+func (x *IError) MarshalJSON() ([]byte, error) {
+	return x.marshalJSON(true)
+}
+
+func (x *IError) marshalJSON(top bool) ([]byte, error) {
+	v := jsonIError{
+		Err:  json.RawMessage("null"),
+		Code: x.Code,
+		Msg:  x.Msg,
+	}
+	if !x.at.IsZero() {
+		v.Time, v.Goroutine = &x.at, x.gid
+	}
+	if top {
+		v.Fingerprint = Fingerprint(x)
+		v.Breadcrumbs = Breadcrumbs(x)
+		v.Build = jsonBuildInfo()
+	}
+	if inner, ok := x.Err.(*IError); ok {
+		b, err := inner.marshalJSON(false)
+		if err != nil {
+			return nil, err
+		}
+		v.Err = b
+	} else if x.Err != nil {
+		b, err := json.Marshal(x.Err.Error())
+		if err != nil {
+			return nil, err
+		}
+		v.Err = b
+	}
+	return json.Marshal(v)
+}
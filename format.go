@@ -0,0 +1,251 @@
+package ierror
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TraceFormat Trace的输出格式
+type TraceFormat int
+
+const (
+	// FormatDefault 默认格式：从最内层开始，每帧两行 函数 : [信息] / 文件:行号
+	FormatDefault TraceFormat = iota
+	// FormatCompact 单行格式，从最外层开始，每层只保留创建位置，便于grep
+	FormatCompact
+	// FormatJava Java风格，从最外层开始，内层以 Caused by: 引出
+	FormatJava
+	// FormatJSON 单行JSON
+	FormatJSON
+	// FormatLogfmt 单行logfmt
+	FormatLogfmt
+	// FormatRaw 单行JSON的RawTrace，只包含程序计数器和build ID，需要离线符号化后才能阅读
+	// WriteLayers不支持这一格式，按默认格式输出
+	FormatRaw
+)
+
+// renderedLayer 按选项过滤后待输出的一层
+// omittedLayers大于0时这一项只是省略标记，表示此处按TraceLimits省略了若干层
+type renderedLayer struct {
+	Layer
+	frames        []filteredFrame
+	omittedFrames int
+	omittedLayers int
+}
+
+// outerFirst 返回过滤和限制后的各层，最外层在前
+func outerFirst(layers []Layer, opts TraceOptions) []renderedLayer {
+	head, tail := opts.Limits.split(len(layers))
+	return outerFirstSplit(layers, head, tail, opts)
+}
+
+// outerFirstSplit 与outerFirst相同，保留最内侧的head层和最外侧的tail层
+func outerFirstSplit(layers []Layer, head, tail int, opts TraceOptions) []renderedLayer {
+	out := make([]renderedLayer, 0, head+tail+1)
+	for i := len(layers) - 1; i >= 0; i-- {
+		if i < len(layers)-tail && i >= head {
+			if i == head {
+				out = append(out, renderedLayer{omittedLayers: len(layers) - head - tail})
+			}
+			continue
+		}
+		frames, omitted := opts.Limits.frames(opts.Filter.apply(layers[i].Frames))
+		out = append(out, renderedLayer{Layer: layers[i], frames: frames, omittedFrames: omitted})
+	}
+	return out
+}
+
+// traceOuterFirst 以render输出最外层在前的格式
+// 设置了MaxBytes时与默认格式一样始终保留最内层和最外层，中间的层从内向外尽量多地保留，其余的层以省略标记代替
+func traceOuterFirst(tw *traceWriter, src traceSource, opts TraceOptions,
+	render func(*traceWriter, traceSource, []renderedLayer, TraceOptions)) {
+	head, tail := opts.Limits.split(len(src.layers))
+	if opts.Limits.MaxBytes <= 0 {
+		render(tw, src, outerFirstSplit(src.layers, head, tail, opts), opts)
+		return
+	}
+	if tail == 0 && head > 1 {
+		head, tail = head-1, 1
+	}
+	try := func(keep int) string {
+		var sb strings.Builder
+		t := &traceWriter{w: bufio.NewWriter(&sb), color: tw.color}
+		render(t, src, outerFirstSplit(src.layers, keep, tail, opts), opts)
+		_ = t.flush()
+		return sb.String()
+	}
+	// 输出长度随保留的层数增加，二分查找放得下的最多层数，至少保留最内层
+	out := try(head)
+	if len(out) > opts.Limits.MaxBytes && head > 1 {
+		lo, hi := 1, head-1
+		out = try(lo)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if s := try(mid); len(s) <= opts.Limits.MaxBytes {
+				lo, out = mid, s
+			} else {
+				hi = mid - 1
+			}
+		}
+	}
+	tw.WriteString(out)
+}
+
+// layerTitle 一层的简短描述：[code] msg，code为0时省略
+func layerTitle(l Layer) string {
+	if l.Code == 0 {
+		return l.Msg
+	}
+	return fmt.Sprintf("[%d] %s", l.Code, l.Msg)
+}
+
+func traceCompact(tw *traceWriter, src traceSource, layers []renderedLayer, opts TraceOptions) {
+	for i, l := range layers {
+		if i > 0 {
+			tw.WriteString(" <- ")
+		}
+		if l.omittedLayers > 0 {
+			tw.printf("... %d layers omitted ...", l.omittedLayers)
+			continue
+		}
+		tw.WriteString(layerTitle(l.Layer))
+		if len(l.frames) > 0 {
+			f := l.frames[0].Frame
+			tw.printf(" @ %s (%s)", f.ShortFunction(), opts.location(f))
+		}
+	}
+}
+
+func traceJava(tw *traceWriter, src traceSource, layers []renderedLayer, opts TraceOptions) {
+	for i, l := range layers {
+		if l.omittedLayers > 0 {
+			tw.WriteString(layersOmitted(l.omittedLayers))
+			continue
+		}
+		if i > 0 {
+			tw.WriteString("\nCaused by: ")
+		}
+		if l.Foreign {
+			tw.WriteString("error: " + l.Msg)
+		} else {
+			tw.WriteString("IError: " + layerTitle(l.Layer))
+		}
+		for _, f := range l.frames {
+			tw.printf("\n\tat %s(%s)", f.ShortFunction(), opts.location(f.Frame))
+			if f.Repeat > 1 {
+				tw.printf(" x%d", f.Repeat)
+			}
+		}
+		if l.omittedFrames > 0 {
+			tw.WriteString(framesOmitted(l.omittedFrames))
+		}
+	}
+}
+
+// jsonTrace FormatJSON输出的结构
+type jsonTrace struct {
+	Error       string      `json:"error"`
+	Code        int32       `json:"code"`
+	Fingerprint string      `json:"fingerprint"`
+	Layers      []jsonLayer `json:"layers"`
+	Breadcrumbs []Crumb     `json:"breadcrumbs,omitempty"`
+	Build       *BuildInfo  `json:"build,omitempty"`
+}
+
+type jsonLayer struct {
+	Code    int         `json:"code"`
+	Msg     string      `json:"msg"`
+	Foreign bool        `json:"foreign,omitempty"`
+	Frames  []jsonFrame `json:"frames,omitempty"`
+	// OmittedFrames 按TraceLimits省略的帧数
+	OmittedFrames int `json:"omitted_frames,omitempty"`
+	// OmittedLayers 大于0时这一项是省略标记，表示此处省略了若干层
+	OmittedLayers int `json:"omitted_layers,omitempty"`
+}
+
+type jsonFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Repeat   int    `json:"repeat,omitempty"`
+}
+
+func traceJSON(tw *traceWriter, src traceSource, layers []renderedLayer, opts TraceOptions) {
+	msg, code, fingerprint := src.summary()
+	v := jsonTrace{
+		Error:       msg,
+		Code:        code,
+		Fingerprint: fingerprint,
+		Breadcrumbs: src.breadcrumbs(),
+	}
+	if src.build {
+		v.Build = jsonBuildInfo()
+	}
+	for _, l := range layers {
+		jl := jsonLayer{
+			Code:          l.Code,
+			Msg:           l.Msg,
+			Foreign:       l.Foreign,
+			OmittedFrames: l.omittedFrames,
+			OmittedLayers: l.omittedLayers,
+		}
+		for _, f := range l.frames {
+			jf := jsonFrame{
+				Function: f.Function,
+				File:     opts.file(f.Frame, opts.moduleRel(f.Frame)),
+				Line:     f.Line,
+			}
+			if f.Repeat > 1 {
+				jf.Repeat = f.Repeat
+			}
+			jl.Frames = append(jl.Frames, jf)
+		}
+		v.Layers = append(v.Layers, jl)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		tw.WriteString(msg)
+		return
+	}
+	_, _ = tw.Write(b)
+}
+
+func traceLogfmt(tw *traceWriter, src traceSource, layers []renderedLayer, opts TraceOptions) {
+	msg, code, fingerprint := src.summary()
+	tw.printf("error=%s code=%d fingerprint=%s", logfmtValue(msg), code, fingerprint)
+	for i, l := range layers {
+		p := "l" + strconv.Itoa(i) + "."
+		if l.omittedLayers > 0 {
+			tw.printf(" %somitted_layers=%d", p, l.omittedLayers)
+			continue
+		}
+		tw.printf(" %scode=%d %smsg=%s", p, l.Code, p, logfmtValue(l.Msg))
+		if l.Foreign {
+			tw.printf(" %sforeign=true", p)
+			continue
+		}
+		stack := make([]string, 0, len(l.frames))
+		for _, f := range l.frames {
+			s := f.ShortFunction() + " " + opts.file(f.Frame, opts.moduleRel(f.Frame)) + ":" + strconv.Itoa(f.Line)
+			if f.Repeat > 1 {
+				s += " x" + strconv.Itoa(f.Repeat)
+			}
+			stack = append(stack, s)
+		}
+		tw.printf(" %sstack=%s", p, logfmtValue(strings.Join(stack, ";")))
+		if l.omittedFrames > 0 {
+			tw.printf(" %somitted_frames=%d", p, l.omittedFrames)
+		}
+	}
+}
+
+// logfmtValue 含有空白、引号或等号的值加上引号
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
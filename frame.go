@@ -0,0 +1,138 @@
+package ierror
+
+import (
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Function string `json:"function"` // 完整的函数名，例如 github.com/a/b.(*T).M
+	Package  string `json:"package"`  // 函数所在的包路径，例如 github.com/a/b
+	File     string `json:"file"`     // 编译时的源文件绝对路径
+	Line     int    `json:"line"`
+	InApp    bool   `json:"in_app"` // 是否属于主模块（或main包）的代码
+}
+
+// ShortFunction 去掉包路径中目录部分的函数名，例如 b.(*T).M，与Trace中的展示一致
+func (f Frame) ShortFunction() string {
+	return f.Function[strings.LastIndex(f.Function, "/")+1:]
+}
+
+// Layer 错误链上的一层
+type Layer struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	// Foreign 为true表示这一层不是IError，没有调用栈，Msg为其Error()的内容
+	Foreign bool `json:"foreign,omitempty"`
+	// Time、Goroutine 只有开启EnableTimestamps后才有值，未开启时Time为nil
+	Time      *time.Time `json:"time,omitempty"`
+	Goroutine uint64     `json:"goroutine,omitempty"`
+	// Elapsed 相对内层的创建时间经过的时长，内层没有时间信息时为0
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+	Frames  []Frame       `json:"frames,omitempty"`
+}
+
+// Layers 将错误链展开为结构化的各层，顺序与Trace一致：最内层在前
+// err不是IError时返回nil
+func Layers(err error) []Layer {
+	ge, ok := err.(*IError)
+	if !ok {
+		return nil
+	}
+	mod := GetBuildInfo().Module
+	var out []Layer
+	for ge != nil {
+		inner, _ := ge.Err.(*IError)
+		l := Layer{
+			Code:      ge.Code,
+			Msg:       ge.Msg,
+			Goroutine: ge.gid,
+		}
+		if !ge.at.IsZero() {
+			at := ge.at
+			l.Time = &at
+		}
+		if inner != nil && !ge.at.IsZero() && !inner.at.IsZero() {
+			l.Elapsed = ge.at.Sub(inner.at)
+		}
+		for _, f := range layerFrames(ge) {
+			pkg := FuncPackage(f.Function)
+			l.Frames = append(l.Frames, Frame{
+				Function: f.Function,
+				Package:  pkg,
+				File:     f.File,
+				Line:     f.Line,
+				InApp:    inAppPackage(pkg, mod),
+			})
+		}
+		out = append(out, l)
+		if inner == nil && ge.Err != nil {
+			out = append(out, Layer{Msg: ge.Err.Error(), Foreign: true})
+		}
+		ge = inner
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// layerFrames 返回IError这一层的调用栈，与Trace中展示的范围一致
+// 第一帧是错误被创建的位置
+func layerFrames(ge *IError) []runtime.Frame {
+	n := ge.depth + 1
+	if n > cap(ge.pc) {
+		n = cap(ge.pc)
+	}
+	if n <= 0 {
+		return nil
+	}
+	var out []runtime.Frame
+	frames := runtime.CallersFrames(ge.pc[:n])
+	for {
+		// 与Trace保持一致，最后一帧（通常是runtime.goexit）不展示
+		f, more := frames.Next()
+		if !more {
+			return out
+		}
+		out = append(out, f)
+	}
+}
+
+// FuncPackage 从完整的函数名中取出包路径，与Frame.Package的规则相同
+// 例如 github.com/a/b.(*T).M 返回 github.com/a/b
+func FuncPackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	dot := strings.Index(fn[slash+1:], ".")
+	if dot < 0 {
+		return fn
+	}
+	return fn[:slash+1+dot]
+}
+
+// inAppPackage 判断包是否属于prefixes中的任一模块，main包始终视为业务代码
+func inAppPackage(pkg string, prefixes ...string) bool {
+	if pkg == "main" {
+		return true
+	}
+	for _, p := range prefixes {
+		if p != "" && (pkg == p || strings.HasPrefix(pkg, p+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// stdlibPackage 判断是否为标准库的包：第一段路径中不含点号
+func stdlibPackage(pkg string) bool {
+	if pkg == "main" || pkg == "" {
+		return false
+	}
+	first := pkg
+	if i := strings.Index(pkg, "/"); i >= 0 {
+		first = pkg[:i]
+	}
+	return !strings.Contains(first, ".")
+}
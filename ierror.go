@@ -1,11 +1,13 @@
 package ierror
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"runtime"
-	"strings"
+	"time"
 )
 
 const (
@@ -22,8 +24,13 @@ type IError struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 
-	pc    []uintptr `json:"-"`
-	depth int       `json:"-"`
+	pc     []uintptr `json:"-"`
+	depth  int       `json:"-"`
+	crumbs []Crumb   `json:"-"`
+	at     time.Time `json:"-"`
+	gid    uint64    `json:"-"`
+	// sentinel 由Sentinel创建，参见Is
+	sentinel bool `json:"-"`
 }
 
 func (x *IError) Error() string {
@@ -35,13 +42,21 @@ func (x *IError) Error() string {
 }
 
 func (x *IError) C(skip int) *IError {
+	x.capture(skip + 1)
+	onCreate(context.Background(), x)
+	return x
+}
+
+// capture 记录调用栈，skip的含义与C相同
+func (x *IError) capture(skip int) {
+	x.stamp()
 	pc := make([]uintptr, 32)
 	n := runtime.Callers(skip, pc)
 	x.pc, x.depth = pc[:n], n
-	if e, ok := x.Err.(*IError); ok {
+	// 哨兵错误是共享的全局变量，没有调用栈，不能修改
+	if e, ok := x.Err.(*IError); ok && !e.sentinel {
 		e.depth -= x.depth
 	}
-	return x
 }
 
 // Unwrap是error类型的必要方法
@@ -49,37 +64,21 @@ func (x *IError) Unwrap() error {
 	return x.Err
 }
 
+// Trace 输出err的调用栈，从最内层开始，每一层展示错误信息和调用位置
+// 输出选项见DefaultTraceOptions
 func Trace(err error) string {
-	ge, ok := err.(*IError)
-	if !ok {
-		return err.Error()
-	}
-	str := ""
-	if ge.Err != nil {
-		_, ok = ge.Err.(*IError)
-		str = Trace(ge.Err)
-		if !ok {
-			str = fmt.Sprintf("\nnot.found : %s\n\t/can/not/get/trace/info/:sorry", Trace(ge.Err))
-		}
-	}
-	frames := runtime.CallersFrames(ge.pc[:ge.depth+1])
-	f, ok := frames.Next()
-	if ok {
-		if ge.Code == 0 {
-			str += pretty(&f, fmt.Sprintf("msg: %s", ge.Msg))
-		} else {
-			str += pretty(&f, fmt.Sprintf("msg: %s, code: %d", ge.Msg, ge.Code))
-		}
-	}
-	f, ok = frames.Next()
-	for ok {
-		str += pretty(&f)
-		f, ok = frames.Next()
-	}
-	return str
+	return TraceWith(err, DefaultTraceOptions)
 }
 
 func Wrap(err error, msg string, skip ...int) *IError {
+	if len(skip) == 0 {
+		skip = []int{3}
+	}
+	return wrap(context.Background(), err, 0, msg, skip[0]+1)
+}
+
+// wrap 在捕获调用栈之前就设置好code，使创建时的钩子能拿到完整的错误信息
+func wrap(ctx context.Context, err error, code int, msg string, skip int) *IError {
 	var e = err
 	// 不断解包，直到出现第一个CodeError，用于获取调用栈
 	for {
@@ -98,30 +97,45 @@ func Wrap(err error, msg string, skip ...int) *IError {
 	// 表示e不是*IError类型也不是errors生成的
 	// 此时无法解析出e的类型，直接将e包装起来即可
 	ge := &IError{
-		Code: 0,
-		Err:  e,
-		Msg:  msg,
+		Code:   code,
+		Err:    e,
+		Msg:    msg,
+		crumbs: snapshotCrumbs(ctx),
 	}
-	if len(skip) == 0 {
-		skip = []int{3}
-	}
-	return ge.C(skip[0])
+	ge.capture(skip)
+	onCreate(ctx, ge)
+	return ge
 }
 
 // WrapIError 基于上层error封装出自定义错误
 func WrapIError(err error, code int, msg string) *IError {
-	ge := Wrap(err, msg, 4)
-	ge.Code = code
-	return ge
+	return wrap(context.Background(), err, code, msg, 4)
+}
+
+// WrapIErrorContext 与WrapIError相同，ctx用于关联trace任务、附加面包屑等请求级信息
+func WrapIErrorContext(ctx context.Context, err error, code int, msg string) *IError {
+	return wrap(ctx, err, code, msg, 4)
 }
 
 // NewIError 生成最底层的自定义错误
 func NewIError(code int, msg string) *IError {
+	return newIError(context.Background(), code, msg, 4)
+}
+
+// NewIErrorContext 与NewIError相同，ctx用于关联trace任务、附加面包屑等请求级信息
+func NewIErrorContext(ctx context.Context, code int, msg string) *IError {
+	return newIError(ctx, code, msg, 4)
+}
+
+func newIError(ctx context.Context, code int, msg string, skip int) *IError {
 	ge := &IError{
-		Code: code,
-		Msg:  msg,
+		Code:   code,
+		Msg:    msg,
+		crumbs: snapshotCrumbs(ctx),
 	}
-	return ge.C(3)
+	ge.capture(skip)
+	onCreate(ctx, ge)
+	return ge
 }
 
 // WrapWithFunc 将错误封装一层当前的函数名，并且返回新的错误
@@ -178,10 +192,22 @@ func FirstAs(err error, target **IError) bool {
 }
 
 // ---------------------- 私有方法，只用于code error的 --------------------------
-func pretty(frame *runtime.Frame, msg ...interface{}) string {
+// onCreate 每个IError在C中捕获完调用栈后调用，用于采样、打点等观测
+func onCreate(ctx context.Context, x *IError) {
+	recordProfile(x)
+	traceCreated(ctx, x)
+}
+
+// pretty 输出一帧，repeat大于1时在信息之后标注折叠的次数
+func pretty(w io.Writer, function, location string, repeat int, msg ...interface{}) {
 	//msg = append(msg, frame.Func, frame.Entry)
-	return fmt.Sprintf("\n%s : %v\n\t%s:%d",
-		frame.Function[strings.LastIndex(frame.Function, "/")+1:],
+	suffix := ""
+	if repeat > 1 {
+		suffix = fmt.Sprintf(" x%d", repeat)
+	}
+	_, _ = fmt.Fprintf(w, "\n%s : %v%s\n\t%s",
+		function,
 		msg,
-		frame.File, frame.Line)
+		suffix,
+		location)
 }
@@ -0,0 +1,44 @@
+package ierror
+
+import "fmt"
+
+// TraceLimits Trace输出的大小限制，零值表示不限制
+// 超出限制的部分以明确的标记代替，最内层（根因）和最外层始终完整保留，
+// FormatRaw需要完整的程序计数器离线符号化，不受任何限制
+type TraceLimits struct {
+	// MaxBytes 输出的总字节数上限，超出时从中间开始省略整层，不适用于自定义模板
+	MaxBytes int
+	// MaxLayers 最多输出的层数，超出时保留最内层和最外层各一半，省略中间的层
+	MaxLayers int
+	// MaxFrames 每一层最多输出的帧数，超出时保留靠近创建位置的帧
+	MaxFrames int
+}
+
+// split 按MaxLayers计算n层中需要保留的最内侧层数head和最外侧层数tail，中间的层被省略
+func (lim TraceLimits) split(n int) (head, tail int) {
+	if lim.MaxLayers <= 0 || n <= lim.MaxLayers {
+		return n, 0
+	}
+	max := lim.MaxLayers
+	if max < 2 {
+		max = 2
+	}
+	head = (max + 1) / 2
+	return head, max - head
+}
+
+// frames 按MaxFrames截断一层的帧，返回截断后的帧和省略的帧数
+func (lim TraceLimits) frames(frames []filteredFrame) ([]filteredFrame, int) {
+	if lim.MaxFrames <= 0 || len(frames) <= lim.MaxFrames {
+		return frames, 0
+	}
+	return frames[:lim.MaxFrames], len(frames) - lim.MaxFrames
+}
+
+func layersOmitted(n int) string {
+	return fmt.Sprintf("\n... %d layers omitted ...", n)
+}
+
+func framesOmitted(n int) string {
+	return fmt.Sprintf("\n\t... %d frames omitted ...", n)
+}
@@ -0,0 +1,51 @@
+package ierror
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMaxBytes(t *testing.T) {
+	err := deepError(20)
+	for _, tt := range []struct {
+		name   string
+		format TraceFormat
+		marker string
+	}{
+		{"default", FormatDefault, "layers omitted"},
+		{"compact", FormatCompact, "layers omitted"},
+		{"java", FormatJava, "layers omitted"},
+		{"json", FormatJSON, `"omitted_layers"`},
+		{"logfmt", FormatLogfmt, "omitted_layers="},
+	} {
+		full := TraceWith(err, TraceOptions{Format: tt.format})
+		max := len(full) / 2
+		s := TraceWith(err, TraceOptions{Format: tt.format, Limits: TraceLimits{MaxBytes: max}})
+		if len(s) > max {
+			t.Errorf("%s: %d bytes, limit %d", tt.name, len(s), max)
+		}
+		if !strings.Contains(s, tt.marker) {
+			t.Errorf("%s: no omitted marker in\n%s", tt.name, s)
+		}
+		if !strings.Contains(s, "root") || !strings.Contains(s, "21") {
+			t.Errorf("%s: innermost or outermost layer missing in\n%s", tt.name, s)
+		}
+		if tt.format == FormatJSON && !json.Valid([]byte(s)) {
+			t.Errorf("json: invalid output %s", s)
+		}
+		if s := TraceWith(err, TraceOptions{Format: tt.format, Limits: TraceLimits{MaxBytes: 2 * len(full)}}); s != full {
+			t.Errorf("%s: output within the limit was changed", tt.name)
+		}
+	}
+}
+
+func TestTemplateLimits(t *testing.T) {
+	err := deepError(6)
+	for _, lim := range []TraceLimits{{MaxLayers: 3}, {MaxFrames: 1}, {MaxLayers: 2, MaxFrames: 2}} {
+		want := TraceWith(err, TraceOptions{Limits: lim})
+		if got := TraceWith(err, TraceOptions{Template: TemplateClassic, Limits: lim}); got != want {
+			t.Errorf("%+v: TemplateClassic differs from the default format\ngot:\n%s\nwant:\n%s", lim, got, want)
+		}
+	}
+}
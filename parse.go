@@ -0,0 +1,149 @@
+package ierror
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ansiPattern ANSI颜色和OSC 8超链接的控制序列
+	ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m|\x1b\\]8;;[^\x1b]*\x1b\\\\")
+	// framePattern 函数行：function : [msg] xN，多行的信息合并后再匹配
+	framePattern = regexp.MustCompile(`(?s)^(\S+) : \[(.*)\](?: x(\d+))?$`)
+	// headerPattern 每层第一帧上的信息，与layerHeader对应
+	headerPattern = regexp.MustCompile(`(?s)^msg: (.*?)(?:, code: (-?\d+))?` +
+		`(?:, time: (\S+), goroutine: (\d+)(?:, elapsed: (\S+))?)?$`)
+	// locationPattern 位置行：\tfile:line，后面可能跟着链接
+	locationPattern = regexp.MustCompile(`^\t(.*?):(\d+)(?: (\S+))?$`)
+	// repeatPattern 旧版本把折叠次数写在方括号里：function : [xN]
+	repeatPattern = regexp.MustCompile(`^x(\d+)$`)
+)
+
+const (
+	// foreignLocation not.found层和没有调用栈的IError层的位置行
+	foreignLocation = "\t/can/not/get/trace/info/:sorry"
+	// noStack 没有调用栈的IError层（例如哨兵错误）在函数位置上的占位
+	noStack = "no.stack"
+)
+
+// StripANSI 去掉s中Trace输出的ANSI颜色和OSC 8超链接
+func StripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// ParseTrace 将默认格式的Trace文本解析回结构化的各层，顺序与Trace一致：最内层在前
+// 支持 not.found 和 no.stack 占位层、多行的错误信息、时间信息、重复帧折叠、颜色和超链接，
+// build头部、面包屑、源码片段和省略标记会被跳过，解析出的时间只有时分秒
+// 由于Trace只输出去掉目录的函数名，解析出的Function和Package不含完整的包路径
+func ParseTrace(s string) ([]Layer, error) {
+	lines := strings.Split(strings.ReplaceAll(StripANSI(s), "\r\n", "\n"), "\n")
+	var layers []Layer
+	cur := -1 // 当前层在layers中的下标，-1表示不在IError层中
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "",
+			strings.HasPrefix(line, "build : "),
+			strings.HasPrefix(line, "... "),
+			strings.HasPrefix(line, "\t"):
+			// 空行、头部、省略标记、源码片段和面包屑的内容
+			continue
+		case line == "breadcrumbs :":
+			continue
+		case strings.HasPrefix(line, "not.found : "):
+			// 信息中的换行（例如errors.Join）使其延续到位置行之前的各行
+			msg, end := line, i
+			for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "not.found : "); j++ {
+				if lines[j] == foreignLocation {
+					msg, end = strings.Join(lines[i:j], "\n"), j
+					break
+				}
+			}
+			layers = append(layers, Layer{Msg: strings.TrimPrefix(msg, "not.found : "), Foreign: true})
+			cur = -1
+			i = end
+			continue
+		}
+
+		m := framePattern.FindStringSubmatch(line)
+		if m == nil && strings.Contains(line, " : [") {
+			// 多行的信息：向后合并，直到能够匹配并且下一行是位置行
+			for j := i + 1; j+1 < len(lines); j++ {
+				if mm := framePattern.FindStringSubmatch(strings.Join(lines[i:j+1], "\n")); mm != nil &&
+					locationPattern.MatchString(lines[j+1]) {
+					m, i = mm, j
+					break
+				}
+			}
+		}
+		if m == nil {
+			return nil, fmt.Errorf("ierror: parse trace line %d: unexpected %q", i+1, line)
+		}
+		if i+1 >= len(lines) {
+			return nil, fmt.Errorf("ierror: parse trace line %d: missing location", i+1)
+		}
+		if m[1] == noStack && lines[i+1] == foreignLocation {
+			h := headerPattern.FindStringSubmatch(m[2])
+			if h == nil {
+				return nil, fmt.Errorf("ierror: parse trace line %d: unexpected message %q", i+1, m[2])
+			}
+			layers = append(layers, headerLayer(h))
+			cur = -1
+			i++
+			continue
+		}
+		loc := locationPattern.FindStringSubmatch(lines[i+1])
+		if loc == nil {
+			return nil, fmt.Errorf("ierror: parse trace line %d: bad location %q", i+2, lines[i+1])
+		}
+		i++
+		lineNo, _ := strconv.Atoi(loc[2])
+		f := Frame{
+			Function: m[1],
+			Package:  FuncPackage(m[1]),
+			File:     loc[1],
+			Line:     lineNo,
+		}
+
+		repeat := 1
+		if m[3] != "" {
+			repeat, _ = strconv.Atoi(m[3])
+		}
+		if h := headerPattern.FindStringSubmatch(m[2]); h != nil {
+			layers = append(layers, headerLayer(h))
+			cur = len(layers) - 1
+		} else if r := repeatPattern.FindStringSubmatch(m[2]); r != nil {
+			repeat, _ = strconv.Atoi(r[1])
+		} else if m[2] != "" {
+			return nil, fmt.Errorf("ierror: parse trace line %d: unexpected message %q", i, m[2])
+		}
+		if cur < 0 {
+			return nil, fmt.Errorf("ierror: parse trace line %d: frame outside of a layer", i)
+		}
+		for j := 0; j < repeat; j++ {
+			layers[cur].Frames = append(layers[cur].Frames, f)
+		}
+	}
+	return layers, nil
+}
+
+// headerLayer 由headerPattern的匹配结果还原一层，不含帧
+func headerLayer(h []string) Layer {
+	l := Layer{Msg: h[1]}
+	if h[2] != "" {
+		l.Code, _ = strconv.Atoi(h[2])
+	}
+	if h[3] != "" {
+		if at, err := time.Parse("15:04:05.000000", h[3]); err == nil {
+			l.Time = &at
+		}
+		l.Goroutine, _ = strconv.ParseUint(h[4], 10, 64)
+	}
+	if h[5] != "" {
+		l.Elapsed, _ = time.ParseDuration(h[5])
+	}
+	return l
+}
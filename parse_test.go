@@ -0,0 +1,146 @@
+package ierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 每一层在不同的函数中创建，使每一层都有自己的帧
+
+func parseRead() error { return WrapIError(errors.New("io: closed"), 2, "read") }
+
+func parseChain() error { return WrapIError(parseRead(), 3, "load") }
+
+func parseInner() error { return NewIError(1, "first\nsecond, code: 9]") }
+
+// parseRecurse 递归调用自身，使最内层带有连续重复的帧
+func parseRecurse(n int) error {
+	if n == 0 {
+		return NewIError(1, "deep")
+	}
+	return parseRecurse(n - 1)
+}
+
+func parseZoom() error { return Wrap(parseL1(), "zoom x2") }
+
+func parseL1() error { return NewIError(1, "l1") }
+
+func parseL2() error { return WrapIError(parseL1(), 2, "l2") }
+
+func parseL3() error { return WrapIError(parseL2(), 3, "l3") }
+
+func parseDeep() error { return WrapIError(parseL3(), 4, "l4") }
+
+// layerSummary 与ParseTrace可还原的内容对应：函数只比较去掉目录的部分
+func layerSummary(layers []Layer) string {
+	var sb strings.Builder
+	for _, l := range layers {
+		fmt.Fprintf(&sb, "code=%d foreign=%t msg=%q\n", l.Code, l.Foreign, l.Msg)
+		for _, f := range l.Frames {
+			fmt.Fprintf(&sb, "\t%s %s:%d\n", f.ShortFunction(), f.File, f.Line)
+		}
+	}
+	return sb.String()
+}
+
+func TestParseTraceRoundTrip(t *testing.T) {
+	firstFrames := func(layers []Layer) []Layer {
+		out := append([]Layer(nil), layers...)
+		for i := range out {
+			if len(out[i].Frames) > 1 {
+				out[i].Frames = out[i].Frames[:1]
+			}
+		}
+		return out
+	}
+	// 折叠后只保留连续重复帧中的第一帧，解析时按xN复制
+	collapsed := func(layers []Layer) []Layer {
+		out := append([]Layer(nil), layers...)
+		for i := range out {
+			frames := append([]Frame(nil), out[i].Frames...)
+			for j := 1; j < len(frames); j++ {
+				if frames[j].Function == frames[j-1].Function {
+					frames[j] = frames[j-1]
+				}
+			}
+			out[i].Frames = frames
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		err   func() error
+		opts  TraceOptions
+		setup func() func()
+		// want 由Layers(err)得到期望的结果，为nil时与Layers(err)相同
+		want func([]Layer) []Layer
+	}{
+		{name: "default", err: parseChain},
+		{name: "multi-line foreign", err: func() error {
+			return WrapIError(errors.Join(errors.New("a"), errors.New("b")), 3, "batch failed")
+		}},
+		{name: "multi-line message", err: func() error {
+			return WrapIError(parseInner(), 2, "outer\n\tindented")
+		}},
+		{name: "timestamps", err: parseChain, setup: func() func() {
+			EnableTimestamps(true)
+			return func() { EnableTimestamps(false) }
+		}},
+		{name: "collapse", err: func() error { return parseRecurse(3) }, opts: TraceOptions{Filter: FrameFilter{Collapse: true}},
+			want: collapsed},
+		{name: "message ending in xN", err: parseZoom},
+		{name: "message ending in xN collapsed", err: func() error { return WrapIError(parseRecurse(2), 5, "zoom x2") },
+			opts: TraceOptions{Filter: FrameFilter{Collapse: true}}, want: collapsed},
+		{name: "max layers", err: parseDeep, opts: TraceOptions{Limits: TraceLimits{MaxLayers: 2}},
+			want: func(l []Layer) []Layer { return []Layer{l[0], l[len(l)-1]} }},
+		{name: "max frames", err: parseDeep, opts: TraceOptions{Limits: TraceLimits{MaxFrames: 1}}, want: firstFrames},
+		{name: "color and hyperlinks", err: parseChain,
+			opts: TraceOptions{Color: ColorAlways, Links: LinkVSCode, Hyperlinks: true}},
+		{name: "plain links", err: parseChain, opts: TraceOptions{Links: LinkVSCode}},
+		{name: "breadcrumbs", err: func() error {
+			ctx := WithBreadcrumbs(context.Background(), 4)
+			Breadcrumb(ctx, "query", map[string]interface{}{"table": "users"})
+			return WrapIErrorContext(ctx, parseL1(), 2, "outer")
+		}},
+		{name: "build header", err: parseChain, setup: func() func() {
+			EnableBuildInfo(false, true)
+			return func() { EnableBuildInfo(false, false) }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				defer tt.setup()()
+			}
+			err := tt.err()
+			want := Layers(err)
+			if tt.want != nil {
+				want = tt.want(want)
+			}
+			text := TraceWith(err, tt.opts)
+			got, perr := ParseTrace(text)
+			if perr != nil {
+				t.Fatalf("ParseTrace: %v\n%s", perr, text)
+			}
+			if g, w := layerSummary(got), layerSummary(want); g != w {
+				t.Errorf("round trip mismatch\ngot:\n%s\nwant:\n%s\ntrace:\n%s", g, w, text)
+			}
+			for i := range got {
+				if (got[i].Time == nil) != (want[i].Time == nil) || got[i].Goroutine != want[i].Goroutine {
+					t.Errorf("layer %d: time %v goroutine %d, want %v %d", i, got[i].Time, got[i].Goroutine, want[i].Time, want[i].Goroutine)
+				} else if got[i].Time != nil && got[i].Time.Format("15:04:05.000000") != want[i].Time.Format("15:04:05.000000") {
+					t.Errorf("layer %d: time %v, want %v", i, got[i].Time, want[i].Time)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTraceUnexpected(t *testing.T) {
+	if _, err := ParseTrace("main.f : [msg: x]\nnot a location"); err == nil {
+		t.Error("ParseTrace accepted a frame without a location")
+	}
+}
@@ -0,0 +1,237 @@
+package ierror
+
+import (
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProfilePath 错误创建位置profile默认挂载的路径
+const ProfilePath = "/debug/pprof/ierrors"
+
+var (
+	profileRate  atomic.Int64
+	profileStart = time.Now()
+
+	profileMu      sync.Mutex
+	profileSamples = map[string]*profileSample{}
+)
+
+// profileSample 同一创建位置、同一错误码的累计次数
+type profileSample struct {
+	stack []uintptr
+	code  int
+	count int64
+}
+
+// SetProfileRate 设置错误创建位置的采样率：平均每创建rate个IError随机记录一次调用栈
+// rate<=0 关闭采样（默认），rate为1时记录每一个错误
+func SetProfileRate(rate int) {
+	profileRate.Store(int64(rate))
+}
+
+// ResetProfile 清空已记录的样本
+func ResetProfile() {
+	profileMu.Lock()
+	profileSamples = map[string]*profileSample{}
+	profileStart = time.Now()
+	profileMu.Unlock()
+}
+
+func recordProfile(x *IError) {
+	rate := profileRate.Load()
+	if rate <= 0 || (rate > 1 && rand.Int63n(rate) != 0) {
+		return
+	}
+	// 以错误码加上调用栈作为键
+	key := make([]byte, 0, 8*(len(x.pc)+1))
+	key = strconv.AppendInt(key, int64(x.Code), 10)
+	for _, pc := range x.pc {
+		key = append(key, ',')
+		key = strconv.AppendUint(key, uint64(pc), 16)
+	}
+	profileMu.Lock()
+	s, ok := profileSamples[string(key)]
+	if !ok {
+		s = &profileSample{stack: append([]uintptr(nil), x.pc...), code: x.Code}
+		profileSamples[string(key)] = s
+	}
+	s.count += rate
+	profileMu.Unlock()
+}
+
+// WriteProfile 将采样到的错误创建位置以pprof格式（gzip压缩的protobuf）写入w
+// 样本值为按采样率估算的错误个数，每个样本带有 code 标签，可用 go tool pprof -tagfocus 按错误码过滤
+func WriteProfile(w io.Writer) error {
+	profileMu.Lock()
+	samples := make([]profileSample, 0, len(profileSamples))
+	for _, s := range profileSamples {
+		samples = append(samples, *s)
+	}
+	start := profileStart
+	profileMu.Unlock()
+
+	b := newProfileBuilder()
+	var p protoBuf
+	p.msg(1, b.valueType("errors", "count"))
+	for _, s := range samples {
+		var sample protoBuf
+		ids := make([]uint64, 0, len(s.stack))
+		for _, pc := range s.stack {
+			ids = append(ids, b.location(pc))
+		}
+		sample.packed(1, ids)
+		sample.packed(2, []uint64{uint64(s.count)})
+		var label protoBuf
+		label.uint(1, b.str("code"))
+		label.uint(2, b.str(strconv.Itoa(s.code)))
+		sample.msg(3, label)
+		p.msg(2, sample)
+	}
+	for _, l := range b.locations {
+		p.msg(4, l)
+	}
+	for _, f := range b.functions {
+		p.msg(5, f)
+	}
+	for _, s := range b.strings {
+		p.bytes(6, []byte(s))
+	}
+	p.uint(9, uint64(start.UnixNano()))
+	p.uint(10, uint64(time.Since(start)))
+	p.msg(11, b.valueType("errors", "count"))
+	p.uint(12, 1)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ProfileHandler 返回输出错误创建位置profile的http.Handler，可直接用 go tool pprof 打开
+func ProfileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="ierrors.pb.gz"`)
+		_ = WriteProfile(w)
+	})
+}
+
+// profileBuilder 为profile分配字符串、函数和位置的编号
+type profileBuilder struct {
+	strings   []string
+	strIndex  map[string]uint64
+	functions []protoBuf
+	funcIndex map[string]uint64
+	locations []protoBuf
+	locIndex  map[uintptr]uint64
+}
+
+func newProfileBuilder() *profileBuilder {
+	return &profileBuilder{
+		strings:   []string{""},
+		strIndex:  map[string]uint64{"": 0},
+		funcIndex: map[string]uint64{},
+		locIndex:  map[uintptr]uint64{},
+	}
+}
+
+func (b *profileBuilder) str(s string) uint64 {
+	if i, ok := b.strIndex[s]; ok {
+		return i
+	}
+	i := uint64(len(b.strings))
+	b.strings = append(b.strings, s)
+	b.strIndex[s] = i
+	return i
+}
+
+func (b *profileBuilder) valueType(typ, unit string) protoBuf {
+	var v protoBuf
+	v.uint(1, b.str(typ))
+	v.uint(2, b.str(unit))
+	return v
+}
+
+func (b *profileBuilder) function(name, file string) uint64 {
+	if id, ok := b.funcIndex[name]; ok {
+		return id
+	}
+	id := uint64(len(b.functions) + 1)
+	var f protoBuf
+	f.uint(1, id)
+	f.uint(2, b.str(name))
+	f.uint(3, b.str(name))
+	f.uint(4, b.str(file))
+	b.functions = append(b.functions, f)
+	b.funcIndex[name] = id
+	return id
+}
+
+// location 一个pc对应一个位置，内联展开后的多个函数按从内到外的顺序作为多行记录
+func (b *profileBuilder) location(pc uintptr) uint64 {
+	if id, ok := b.locIndex[pc]; ok {
+		return id
+	}
+	id := uint64(len(b.locations) + 1)
+	var l protoBuf
+	l.uint(1, id)
+	l.uint(3, uint64(pc))
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		f, more := frames.Next()
+		if f.Function != "" {
+			var line protoBuf
+			line.uint(1, b.function(f.Function, f.File))
+			line.uint(2, uint64(f.Line))
+			l.msg(4, line)
+		}
+		if !more {
+			break
+		}
+	}
+	b.locations = append(b.locations, l)
+	b.locIndex[pc] = id
+	return id
+}
+
+// protoBuf 最简单的protobuf编码，只支持profile.proto用到的varint和length-delimited字段
+type protoBuf []byte
+
+func (p *protoBuf) varint(v uint64) {
+	for v >= 0x80 {
+		*p = append(*p, byte(v)|0x80)
+		v >>= 7
+	}
+	*p = append(*p, byte(v))
+}
+
+func (p *protoBuf) uint(field int, v uint64) {
+	p.varint(uint64(field) << 3)
+	p.varint(v)
+}
+
+func (p *protoBuf) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	*p = append(*p, b...)
+}
+
+func (p *protoBuf) msg(field int, m protoBuf) {
+	p.bytes(field, m)
+}
+
+func (p *protoBuf) packed(field int, vs []uint64) {
+	var inner protoBuf
+	for _, v := range vs {
+		inner.varint(v)
+	}
+	p.bytes(field, inner)
+}
@@ -0,0 +1,146 @@
+package ierror
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+// protoFields 解码一层protobuf消息，varint字段的值放在vals，length-delimited字段的内容放在bufs
+type protoFields struct {
+	vals map[int][]uint64
+	bufs map[int][][]byte
+}
+
+func decodeProto(t *testing.T, b []byte) protoFields {
+	t.Helper()
+	pf := protoFields{vals: map[int][]uint64{}, bufs: map[int][][]byte{}}
+	for len(b) > 0 {
+		key := decodeVarint(t, &b)
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			pf.vals[field] = append(pf.vals[field], decodeVarint(t, &b))
+		case 2:
+			n := decodeVarint(t, &b)
+			if uint64(len(b)) < n {
+				t.Fatalf("field %d: truncated", field)
+			}
+			pf.bufs[field] = append(pf.bufs[field], b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("field %d: unexpected wire type %d", field, key&7)
+		}
+	}
+	return pf
+}
+
+func decodeVarint(t *testing.T, b *[]byte) uint64 {
+	t.Helper()
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		if len(*b) == 0 {
+			t.Fatal("truncated varint")
+		}
+		c := (*b)[0]
+		*b = (*b)[1:]
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v
+		}
+	}
+}
+
+// packed 解码packed repeated的varint字段
+func (pf protoFields) packed(t *testing.T, field int) []uint64 {
+	var out []uint64
+	for _, b := range pf.bufs[field] {
+		for len(b) > 0 {
+			out = append(out, decodeVarint(t, &b))
+		}
+	}
+	return out
+}
+
+func profileErr(code int) error { return NewIError(code, "sampled") }
+
+func TestWriteProfile(t *testing.T) {
+	SetProfileRate(1)
+	ResetProfile()
+	defer func() {
+		SetProfileRate(0)
+		ResetProfile()
+	}()
+	for i := 0; i < 3; i++ {
+		_ = profileErr(7)
+	}
+	_ = profileErr(8)
+
+	var buf bytes.Buffer
+	if err := WriteProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := decodeProto(t, raw)
+	strs := make([]string, len(p.bufs[6]))
+	for i, s := range p.bufs[6] {
+		strs[i] = string(s)
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table %q does not start with an empty string", strs)
+	}
+	if st := decodeProto(t, p.bufs[1][0]); strs[st.vals[1][0]] != "errors" || strs[st.vals[2][0]] != "count" {
+		t.Errorf("sample type %s/%s, want errors/count", strs[st.vals[1][0]], strs[st.vals[2][0]])
+	}
+
+	// 位置编号 -> 函数名
+	funcs := map[uint64]string{}
+	for _, b := range p.bufs[5] {
+		f := decodeProto(t, b)
+		funcs[f.vals[1][0]] = strs[f.vals[2][0]]
+	}
+	locs := map[uint64][]string{}
+	for _, b := range p.bufs[4] {
+		l := decodeProto(t, b)
+		for _, line := range l.bufs[4] {
+			locs[l.vals[1][0]] = append(locs[l.vals[1][0]], funcs[decodeProto(t, line).vals[1][0]])
+		}
+	}
+
+	counts := map[string]uint64{}
+	for _, b := range p.bufs[2] {
+		s := decodeProto(t, b)
+		var code string
+		for _, lb := range s.bufs[3] {
+			label := decodeProto(t, lb)
+			if strs[label.vals[1][0]] == "code" {
+				code = strs[label.vals[2][0]]
+			}
+		}
+		values := s.packed(t, 2)
+		if len(values) != 1 {
+			t.Fatalf("code %s: %d values, want 1", code, len(values))
+		}
+		counts[code] += values[0]
+		ids := s.packed(t, 1)
+		if len(ids) == 0 {
+			t.Errorf("code %s: no locations", code)
+			continue
+		}
+		if fns := locs[ids[0]]; !strings.Contains(strings.Join(fns, " "), ".profileErr") {
+			t.Errorf("code %s: leaf location %v, want profileErr", code, fns)
+		}
+	}
+	if counts["7"] != 3 || counts["8"] != 1 || len(counts) != 2 {
+		t.Errorf("counts by code %v, want 7:3 8:1", counts)
+	}
+}
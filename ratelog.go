@@ -0,0 +1,94 @@
+package ierror
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DedupLogger 按指纹对重复的错误去重后打印日志
+// 一个时间窗口内，同一指纹的错误只有第一次会打印完整的Trace，
+// 其余的只计数，在窗口结束时汇总打印一行 "N more occurrences"
+type DedupLogger struct {
+	logger *log.Logger
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	closed  bool
+}
+
+type dedupEntry struct {
+	msg        string
+	suppressed int
+	timer      *time.Timer
+}
+
+// NewDedupLogger 创建DedupLogger，logger为nil时使用log.Default()
+func NewDedupLogger(logger *log.Logger, window time.Duration) *DedupLogger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &DedupLogger{
+		logger:  logger,
+		window:  window,
+		entries: map[string]*dedupEntry{},
+	}
+}
+
+// Log 打印err，窗口内重复出现的错误只计数
+func (d *DedupLogger) Log(err error) {
+	if err == nil {
+		return
+	}
+	fp := Fingerprint(err)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	if e, ok := d.entries[fp]; ok {
+		e.suppressed++
+		return
+	}
+	d.logger.Printf("[%s] %s%s", fp, err.Error(), Trace(err))
+	d.entries[fp] = &dedupEntry{
+		msg:   err.Error(),
+		timer: time.AfterFunc(d.window, func() { d.expire(fp) }),
+	}
+}
+
+// expire 窗口结束：有被抑制的错误时打印汇总并开始新的窗口，否则忘掉这个指纹
+func (d *DedupLogger) expire(fp string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[fp]
+	if !ok || d.closed {
+		return
+	}
+	if e.suppressed == 0 {
+		delete(d.entries, fp)
+		return
+	}
+	d.summary(fp, e)
+	e.timer.Reset(d.window)
+}
+
+func (d *DedupLogger) summary(fp string, e *dedupEntry) {
+	d.logger.Printf("[%s] %s: %d more occurrences in the last %s", fp, e.msg, e.suppressed, d.window)
+	e.suppressed = 0
+}
+
+// Close 打印所有尚未输出的汇总并停止计时，之后的Log调用会被忽略
+func (d *DedupLogger) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for fp, e := range d.entries {
+		e.timer.Stop()
+		if e.suppressed > 0 {
+			d.summary(fp, e)
+		}
+	}
+	d.entries = map[string]*dedupEntry{}
+}
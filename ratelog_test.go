@@ -0,0 +1,96 @@
+package ierror
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 计时器在另一个goroutine中写日志，读写都需要加锁
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func ratelogError() error { return WrapIError(parseL1(), 2, "sync failed") }
+
+// waitFor 等待cond成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestDedupLoggerWindow(t *testing.T) {
+	var out syncBuffer
+	d := NewDedupLogger(log.New(&out, "", 0), 20*time.Millisecond)
+	defer d.Close()
+	err := ratelogError()
+	full := "[" + Fingerprint(err) + "] " + err.Error() + "\n"
+
+	for i := 0; i < 3; i++ {
+		d.Log(err)
+	}
+	if s := out.String(); strings.Count(s, full) != 1 || !strings.Contains(s, "ratelogError") {
+		t.Fatalf("first occurrence not logged with its trace:\n%s", s)
+	}
+	waitFor(t, "summary", func() bool { return strings.Contains(out.String(), ": 2 more occurrences in the last 20ms") })
+
+	// 下一个窗口内没有再出现，忘掉这个指纹，之后再出现时重新打印完整的Trace
+	waitFor(t, "the fingerprint to be forgotten", func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.entries) == 0
+	})
+	d.Log(err)
+	if s := out.String(); strings.Count(s, full) != 2 {
+		t.Errorf("error was not logged in full after a quiet window:\n%s", s)
+	}
+	if s := out.String(); strings.Count(s, "more occurrences") != 1 {
+		t.Errorf("unexpected summaries:\n%s", s)
+	}
+}
+
+func TestDedupLoggerClose(t *testing.T) {
+	var out syncBuffer
+	d := NewDedupLogger(log.New(&out, "", 0), time.Hour)
+	err, other := ratelogError(), parseChain()
+	for i := 0; i < 4; i++ {
+		d.Log(err)
+	}
+	d.Log(other)
+	d.Log(nil)
+	if s := out.String(); strings.Contains(s, "more occurrences") {
+		t.Fatalf("summary before the window ended:\n%s", s)
+	}
+	d.Close()
+	s := out.String()
+	if !strings.Contains(s, "["+Fingerprint(err)+"] "+err.Error()+": 3 more occurrences in the last 1h0m0s") {
+		t.Errorf("Close did not flush the pending count:\n%s", s)
+	}
+	if strings.Count(s, "more occurrences") != 1 {
+		t.Errorf("summary printed for an error without repeats:\n%s", s)
+	}
+	d.Log(err)
+	if out.String() != s {
+		t.Error("Log after Close wrote output")
+	}
+}
@@ -0,0 +1,137 @@
+package ierror
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// RawTrace 未符号化的调用栈，只记录程序计数器和二进制文件的build ID，体积远小于Trace文本
+// 可以在没有符号表的线上环境记录，之后使用 ierror symbolize -binary 对照同一个二进制文件还原
+type RawTrace struct {
+	BuildID string `json:"build_id"`
+	// Anchor RawOf在当前进程中的入口地址，符号化时用于计算地址随机化（PIE）带来的偏移
+	Anchor uint64     `json:"anchor"`
+	Layers []RawLayer `json:"layers"`
+}
+
+// RawLayer RawTrace中的一层，顺序与Layers一致：最内层在前
+type RawLayer struct {
+	Code    int      `json:"code"`
+	Msg     string   `json:"msg"`
+	Foreign bool     `json:"foreign,omitempty"`
+	PCs     []uint64 `json:"pcs,omitempty"`
+}
+
+// RawAnchor Anchor对应的函数名，符号化时在符号表中查找它的地址
+const RawAnchor = "github.com/RanFeng/ierror.RawOf"
+
+// RawOf 返回err未符号化的调用栈，err不是IError时返回nil
+func RawOf(err error) *RawTrace {
+	ge, ok := err.(*IError)
+	if !ok {
+		return nil
+	}
+	r := &RawTrace{
+		BuildID: selfBuildID(),
+		Anchor:  uint64(reflect.ValueOf(RawOf).Pointer()),
+	}
+	for ge != nil {
+		inner, _ := ge.Err.(*IError)
+		r.Layers = append(r.Layers, RawLayer{Code: ge.Code, Msg: ge.Msg, PCs: layerPCs(ge)})
+		if inner == nil && ge.Err != nil {
+			r.Layers = append(r.Layers, RawLayer{Msg: ge.Err.Error(), Foreign: true})
+		}
+		ge = inner
+	}
+	for i, j := 0, len(r.Layers)-1; i < j; i, j = i+1, j-1 {
+		r.Layers[i], r.Layers[j] = r.Layers[j], r.Layers[i]
+	}
+	return r
+}
+
+// layerPCs 返回IError这一层的程序计数器，范围与layerFrames一致：不含最后一帧
+func layerPCs(ge *IError) []uint64 {
+	n := ge.depth + 1
+	if n > len(ge.pc) {
+		n = len(ge.pc)
+	}
+	if n <= 1 {
+		return nil
+	}
+	out := make([]uint64, n-1)
+	for i, pc := range ge.pc[:n-1] {
+		out[i] = uint64(pc)
+	}
+	return out
+}
+
+func traceRaw(tw *traceWriter, ge *IError) {
+	b, err := json.Marshal(RawOf(ge))
+	if err != nil {
+		tw.WriteString(ge.Error())
+		return
+	}
+	_, _ = tw.Write(b)
+}
+
+var (
+	selfBuildOnce sync.Once
+	selfBuild     string
+)
+
+// selfBuildID 返回当前进程可执行文件的build ID，只读取一次，读取失败时为空字符串
+func selfBuildID() string {
+	selfBuildOnce.Do(func() {
+		if exe, err := os.Executable(); err == nil {
+			selfBuild, _ = ReadBuildID(exe)
+		}
+	})
+	return selfBuild
+}
+
+// ReadBuildID 读取Go可执行文件的build ID，与 go tool buildid 的输出相同
+// ELF文件读取 .note.go.buildid 段，其他格式在文件开头查找build ID的标记
+func ReadBuildID(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if ef, err := elf.NewFile(f); err == nil {
+		if s := ef.Section(".note.go.buildid"); s != nil {
+			data, err := s.Data()
+			if err != nil {
+				return "", err
+			}
+			// 4字节名称长度、4字节描述长度、4字节类型、名称 "Go\x00\x00"，之后是描述即build ID
+			if len(data) >= 16 {
+				size := int(ef.ByteOrder.Uint32(data[4:]))
+				if 16+size <= len(data) {
+					return string(data[16 : 16+size]), nil
+				}
+			}
+			return "", errors.New("ierror: malformed build ID note")
+		}
+	}
+	buf := make([]byte, 32*1024)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	const marker = "\xff Go build ID: \""
+	i := bytes.Index(buf[:n], []byte(marker))
+	if i < 0 {
+		return "", errors.New("ierror: build ID not found")
+	}
+	id := buf[i+len(marker) : n]
+	if j := bytes.IndexByte(id, '"'); j >= 0 {
+		return string(id[:j]), nil
+	}
+	return "", errors.New("ierror: malformed build ID")
+}
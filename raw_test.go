@@ -0,0 +1,81 @@
+package ierror
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestRawOf(t *testing.T) {
+	if RawOf(errors.New("plain")) != nil {
+		t.Error("RawOf of a foreign error is not nil")
+	}
+	err := parseChain()
+	raw, layers := RawOf(err), Layers(err)
+	if raw.Anchor == 0 {
+		t.Error("no anchor")
+	}
+	if len(raw.Layers) != len(layers) {
+		t.Fatalf("%d raw layers, want %d", len(raw.Layers), len(layers))
+	}
+	for i, rl := range raw.Layers {
+		l := layers[i]
+		if rl.Code != l.Code || rl.Msg != l.Msg || rl.Foreign != l.Foreign {
+			t.Errorf("layer %d: %+v, want code %d msg %q foreign %t", i, rl, l.Code, l.Msg, l.Foreign)
+		}
+		// 程序计数器的范围与Layers中的帧一一对应
+		pcs := make([]uintptr, len(rl.PCs))
+		for j, pc := range rl.PCs {
+			pcs[j] = uintptr(pc)
+		}
+		var got []string
+		if len(pcs) > 0 {
+			frames := runtime.CallersFrames(pcs)
+			for {
+				f, more := frames.Next()
+				got = append(got, f.Function)
+				if !more {
+					break
+				}
+			}
+		}
+		if len(got) != len(l.Frames) {
+			t.Errorf("layer %d: %d frames %v, want %d", i, len(got), got, len(l.Frames))
+			continue
+		}
+		for j, f := range l.Frames {
+			if got[j] != f.Function {
+				t.Errorf("layer %d frame %d: %s, want %s", i, j, got[j], f.Function)
+			}
+		}
+	}
+}
+
+func TestLayerPCs(t *testing.T) {
+	pc := []uintptr{10, 20, 30, 40}
+	for _, tt := range []struct {
+		depth int
+		want  []uint64
+	}{
+		{depth: 0, want: nil},
+		{depth: 1, want: []uint64{10}},
+		{depth: 3, want: []uint64{10, 20, 30}},
+		// 深度超过记录的帧数时截断，最后一帧不输出
+		{depth: 9, want: []uint64{10, 20, 30}},
+	} {
+		got := layerPCs(&IError{pc: pc, depth: tt.depth})
+		if len(got) != len(tt.want) {
+			t.Errorf("depth %d: %v, want %v", tt.depth, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("depth %d: %v, want %v", tt.depth, got, tt.want)
+				break
+			}
+		}
+	}
+	if got := layerPCs(&IError{}); got != nil {
+		t.Errorf("no pcs: %v, want nil", got)
+	}
+}
@@ -0,0 +1,82 @@
+package ierror
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// CodeInfo 错误码的元信息，通常由 ierrgen 根据错误码目录生成的代码在init中注册
+type CodeInfo struct {
+	Code int `json:"code"`
+	// Name 错误码的名称，导出TypeScript定义时用作enum的成员名，应为合法的标识符
+	Name string `json:"name"`
+	// Message 消息模板，参数以 {name} 表示
+	Message string `json:"message"`
+	Family  string `json:"family,omitempty"`
+	// HTTP 对应的HTTP状态码，0表示未指定
+	HTTP int `json:"http,omitempty"`
+	// GRPC 对应的gRPC状态码，取值与 google.golang.org/grpc/codes 相同，0表示OK或未指定
+	GRPC      int  `json:"grpc,omitempty"`
+	Retryable bool `json:"retryable,omitempty"`
+	// Translations 各语言的消息模板，键为语言标签，例如 zh-CN
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[int]CodeInfo{}
+)
+
+// RegisterCode 注册错误码的元信息，同一个错误码注册两次时panic
+func RegisterCode(info CodeInfo) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if old, ok := registry[info.Code]; ok {
+		panic(fmt.Sprintf("ierror: code %d registered twice: %s and %s", info.Code, old.Name, info.Name))
+	}
+	registry[info.Code] = info
+}
+
+// LookupCode 返回已注册的错误码的元信息
+func LookupCode(code int) (CodeInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	info, ok := registry[code]
+	return info, ok
+}
+
+// Codes 返回所有已注册的错误码，按错误码排序
+func Codes() []CodeInfo {
+	registryMu.RLock()
+	out := make([]CodeInfo, 0, len(registry))
+	for _, info := range registry {
+		out = append(out, info)
+	}
+	registryMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// Sentinel 创建不带调用栈的哨兵错误，errors.Is 按错误码将链上的IError与之匹配
+func Sentinel(code int, msg string) *IError {
+	return &IError{Code: code, Msg: msg, sentinel: true}
+}
+
+// Is 供errors.Is使用，target为Sentinel创建的哨兵错误时比较错误码
+func (x *IError) Is(target error) bool {
+	t, ok := target.(*IError)
+	return ok && t.sentinel && t.Code == x.Code
+}
+
+// NewIErrorSkip 与NewIErrorContext相同，skip为额外跳过的调用层数，
+// 供封装了NewIError的函数使用，使调用栈从封装函数的调用方开始，例如 ierrgen 生成的构造函数传入1
+func NewIErrorSkip(ctx context.Context, skip int, code int, msg string) *IError {
+	return newIError(ctx, code, msg, skip+4)
+}
+
+// WrapIErrorSkip 与WrapIErrorContext相同，skip的含义与NewIErrorSkip相同
+func WrapIErrorSkip(ctx context.Context, skip int, err error, code int, msg string) *IError {
+	return wrap(ctx, err, code, msg, skip+4)
+}
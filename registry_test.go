@@ -0,0 +1,53 @@
+package ierror
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var errTestSentinel = Sentinel(40401, "user not found")
+
+// go test -race 检查包装共享的哨兵错误时不会写入它
+func TestSentinelWrapConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := WrapIError(errTestSentinel, 1, "lookup"); !errors.Is(err, errTestSentinel) {
+					t.Error("wrapped sentinel does not match errors.Is")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if errTestSentinel.depth != 0 || errTestSentinel.pc != nil {
+		t.Errorf("sentinel was modified: depth %d", errTestSentinel.depth)
+	}
+}
+
+func TestSentinelTrace(t *testing.T) {
+	err := Wrap(errTestSentinel, "y")
+	text := Trace(err)
+	if !strings.Contains(text, "no.stack : [msg: user not found, code: 40401]") || !strings.Contains(text, "[msg: y]") {
+		t.Errorf("sentinel layer missing from trace:\n%s", text)
+	}
+	layers, perr := ParseTrace(text)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	if g, w := layerSummary(layers), layerSummary(Layers(err)); g != w {
+		t.Errorf("round trip mismatch\ngot:\n%s\nwant:\n%s", g, w)
+	}
+}
+
+func TestSentinelTemplateClassic(t *testing.T) {
+	err := Wrap(errTestSentinel, "y")
+	if got, want := TraceWith(err, TraceOptions{Template: TemplateClassic}), Trace(err); got != want {
+		t.Errorf("TemplateClassic differs from the default format\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package ierror
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// reportFrame 报告中展示的一帧
+type reportFrame struct {
+	Function string
+	Location string
+	Link     template.URL
+	InApp    bool
+	Repeat   int
+}
+
+// reportLayer 报告中展示的一层
+type reportLayer struct {
+	Title   string
+	Code    int
+	Msg     string
+	Foreign bool
+	Frames  []reportFrame
+}
+
+// report RenderHTML和RenderMarkdown共用的数据，各层最外层在前
+type report struct {
+	Error       string
+	Code        int32
+	Fingerprint string
+	Layers      []reportLayer
+	Breadcrumbs []Crumb
+}
+
+// newReport 按DefaultTraceOptions中的路径、链接和过滤选项整理报告数据
+func newReport(err error) report {
+	opts := DefaultTraceOptions
+	r := report{
+		Error:       err.Error(),
+		Code:        GetErrorCode(err),
+		Fingerprint: Fingerprint(err),
+		Breadcrumbs: Breadcrumbs(err),
+	}
+	ge, ok := err.(*IError)
+	if !ok {
+		r.Layers = []reportLayer{{Title: err.Error(), Msg: err.Error(), Foreign: true}}
+		return r
+	}
+	for _, l := range outerFirst(Layers(ge), opts) {
+		rl := reportLayer{Title: layerTitle(l.Layer), Code: l.Code, Msg: l.Msg, Foreign: l.Foreign}
+		for _, f := range l.frames {
+			rel := opts.moduleRel(f.Frame)
+			rl.Frames = append(rl.Frames, reportFrame{
+				Function: f.ShortFunction(),
+				Location: opts.file(f.Frame, rel) + ":" + strconv.Itoa(f.Line),
+				// 链接由location生成，只可能是http(s)、vscode或goland协议
+				Link:   template.URL(opts.link(f.Frame, rel)),
+				InApp:  f.InApp,
+				Repeat: f.Repeat,
+			})
+		}
+		r.Layers = append(r.Layers, rl)
+	}
+	return r
+}
+
+// RenderHTML 将错误渲染为可折叠的HTML片段，每一层一个<details>，内容均已转义
+// 路径、链接和帧过滤使用DefaultTraceOptions
+func RenderHTML(err error) string {
+	var sb strings.Builder
+	if e := htmlReportTmpl.Execute(&sb, newReport(err)); e != nil {
+		return html.EscapeString(err.Error())
+	}
+	return sb.String()
+}
+
+var htmlReportTmpl = template.Must(template.New("report").Parse(`<div class="ierror">
+<h3 class="ierror-error">{{.Error}}</h3>
+<p>code: <code>{{.Code}}</code> fingerprint: <code>{{.Fingerprint}}</code></p>
+{{range .Layers}}<details open class="ierror-layer">
+<summary>{{if .Foreign}}error: {{end}}{{.Title}}</summary>
+{{if .Frames}}<ol>
+{{range .Frames}}<li class="{{if .InApp}}ierror-app{{else}}ierror-lib{{end}}"><code>{{.Function}}</code> {{if .Link}}<a href="{{.Link}}">{{.Location}}</a>{{else}}{{.Location}}{{end}}{{if gt .Repeat 1}} x{{.Repeat}}{{end}}</li>
+{{end}}</ol>
+{{end}}</details>
+{{end}}{{if .Breadcrumbs}}<details class="ierror-breadcrumbs">
+<summary>breadcrumbs</summary>
+<table>
+{{range .Breadcrumbs}}<tr><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Msg}}</td><td>{{range $k, $v := .Fields}}{{$k}}={{$v}} {{end}}</td></tr>
+{{end}}</table>
+</details>
+{{end}}</div>
+`))
+
+// RenderMarkdown 将错误渲染为Markdown，每一层使用<details>折叠，适合粘贴到bug报告中
+// 路径、链接和帧过滤使用DefaultTraceOptions
+func RenderMarkdown(err error) string {
+	r := newReport(err)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s\n\n", mdEscape(r.Error))
+	fmt.Fprintf(&sb, "code: %s fingerprint: %s\n", mdCode(strconv.Itoa(int(r.Code))), mdCode(r.Fingerprint))
+	for _, l := range r.Layers {
+		title := l.Title
+		if l.Foreign {
+			title = "error: " + title
+		}
+		fmt.Fprintf(&sb, "\n<details open>\n<summary>%s</summary>\n\n", html.EscapeString(title))
+		for i, f := range l.Frames {
+			loc := mdEscape(f.Location)
+			if f.Link != "" {
+				loc = fmt.Sprintf("[%s](<%s>)", loc, strings.NewReplacer("<", "%3C", ">", "%3E").Replace(string(f.Link)))
+			}
+			fmt.Fprintf(&sb, "%d. %s %s", i+1, mdCode(f.Function), loc)
+			if f.Repeat > 1 {
+				fmt.Fprintf(&sb, " x%d", f.Repeat)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n</details>\n")
+	}
+	if len(r.Breadcrumbs) > 0 {
+		sb.WriteString("\n<details>\n<summary>breadcrumbs</summary>\n\n")
+		for _, c := range r.Breadcrumbs {
+			fmt.Fprintf(&sb, "- %s %s", c.Time.Format("15:04:05.000"), mdEscape(c.Msg))
+			if len(c.Fields) > 0 {
+				fmt.Fprintf(&sb, " %s", mdCode(fmt.Sprint(c.Fields)))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n</details>\n")
+	}
+	return sb.String()
+}
+
+// mdEscape 转义Markdown中有特殊含义的字符，同时转义HTML
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "{", `\{`, "}", `\}`,
+	"[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "#", `\#`, "+", `\+`,
+	"-", `\-`, "!", `\!`, "|", `\|`, "<", "&lt;", ">", "&gt;", "&", "&amp;",
+	"\n", " ", "\r", " ",
+)
+
+func mdEscape(s string) string {
+	return mdEscaper.Replace(s)
+}
+
+// mdCode 将s放入行内代码，s中含有反引号时使用更长的反引号包裹
+func mdCode(s string) string {
+	s = strings.NewReplacer("\n", " ", "\r", " ").Replace(s)
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
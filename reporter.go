@@ -0,0 +1,291 @@
+package ierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Record 上报时序列化后的一条错误
+type Record struct {
+	Time        time.Time       `json:"time"`
+	Code        int32           `json:"code"`
+	Msg         string          `json:"msg"`
+	Fingerprint string          `json:"fingerprint"`
+	Error       json.RawMessage `json:"error"`
+	Trace       string          `json:"trace,omitempty"`
+}
+
+// NewRecord 将err序列化为Record，IError使用其JSON结构，其他error使用Error()的内容
+func NewRecord(err error, withTrace bool) Record {
+	rec := Record{
+		Time:        time.Now(),
+		Code:        GetErrorCode(err),
+		Msg:         err.Error(),
+		Fingerprint: Fingerprint(err),
+	}
+	var b []byte
+	if ge, ok := err.(*IError); ok {
+		b, _ = ge.MarshalJSON()
+	}
+	if b == nil {
+		b, _ = json.Marshal(err.Error())
+	}
+	rec.Error = b
+	if withTrace {
+		rec.Trace = Trace(err)
+	}
+	return rec
+}
+
+// Sink 上报的目的地，Send会在Reporter的后台协程中被串行调用
+// ctx带有ReporterOptions.SendTimeout的超时，Send应在ctx结束时尽快返回
+// 实现了io.Closer的Sink会在Reporter关闭时被关闭
+type Sink interface {
+	Send(ctx context.Context, records []Record) error
+}
+
+// DropPolicy 队列满时的处理策略
+type DropPolicy int
+
+const (
+	// DropNewest 丢弃正在上报的错误
+	DropNewest DropPolicy = iota
+	// DropOldest 丢弃队列中最早的错误，为新错误腾出位置
+	DropOldest
+	// Block 阻塞上报方，直到队列有空位或ctx结束
+	Block
+)
+
+// ReporterOptions Reporter的配置，零值字段使用默认值
+type ReporterOptions struct {
+	QueueSize     int           // 队列容量，默认1024
+	BatchSize     int           // 单批最多发送的条数，默认100
+	FlushInterval time.Duration // 未攒满一批时的发送间隔，默认1s
+	Policy        DropPolicy    // 队列满时的处理策略
+	WithTrace     bool          // 是否在Record中附带Trace
+	SendTimeout   time.Duration // 每一批发送到每个Sink的超时时间，默认10s
+}
+
+// ReporterStats Reporter的计数器
+type ReporterStats struct {
+	Reported uint64 // 成功进入队列的条数
+	Dropped  uint64 // 因队列已满或Reporter已关闭而丢弃的条数
+	Sent     uint64 // 成功发送到所有Sink的条数
+	Failed   uint64 // 发送到任一Sink失败的条数
+}
+
+// ErrReporterClosed Reporter已关闭
+var ErrReporterClosed = errors.New("ierror: reporter closed")
+
+// Reporter 异步的错误上报器：错误先进入有界队列，再由后台协程攒批发送到各个Sink
+type Reporter struct {
+	opts  ReporterOptions
+	sinks []Sink
+
+	queue   chan Record
+	flushes chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	// mu 入队时持有读锁、关闭时持有写锁，保证关闭之后不会再有错误进入队列
+	mu       sync.RWMutex
+	closed   bool
+	reported atomic.Uint64
+	dropped  atomic.Uint64
+	sent     atomic.Uint64
+	failed   atomic.Uint64
+}
+
+// NewReporter 创建并启动一个Reporter，使用完毕后需要调用Close
+func NewReporter(opts ReporterOptions, sinks ...Sink) *Reporter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = 10 * time.Second
+	}
+	r := &Reporter{
+		opts:    opts,
+		sinks:   sinks,
+		queue:   make(chan Record, opts.QueueSize),
+		flushes: make(chan chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// Report 将err序列化后放入队列，返回是否成功入队
+func (r *Reporter) Report(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	traceReported(ctx, err)
+	rec := NewRecord(err, r.opts.WithTrace)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return false
+	}
+	switch r.opts.Policy {
+	case Block:
+		// 持有读锁时Close无法关闭stop，后台协程仍在消费队列，阻塞终将结束
+		select {
+		case r.queue <- rec:
+		case <-ctx.Done():
+			r.dropped.Add(1)
+			return false
+		}
+	case DropOldest:
+		for {
+			select {
+			case r.queue <- rec:
+				r.reported.Add(1)
+				return true
+			default:
+			}
+			select {
+			case <-r.queue:
+				r.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case r.queue <- rec:
+		default:
+			r.dropped.Add(1)
+			return false
+		}
+	}
+	r.reported.Add(1)
+	return true
+}
+
+// Flush 等待队列中已有的错误发送完毕
+func (r *Reporter) Flush(ctx context.Context) error {
+	ch := make(chan struct{})
+	select {
+	case r.flushes <- ch:
+	case <-r.done:
+		return ErrReporterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 停止接收新的错误，发送队列中剩余的错误并关闭各个Sink
+func (r *Reporter) Close(ctx context.Context) error {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.stop)
+		r.mu.Unlock()
+	})
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var errs []error
+	for _, s := range r.sinks {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats 返回当前的计数器
+func (r *Reporter) Stats() ReporterStats {
+	return ReporterStats{
+		Reported: r.reported.Load(),
+		Dropped:  r.dropped.Load(),
+		Sent:     r.sent.Load(),
+		Failed:   r.failed.Load(),
+	}
+}
+
+func (r *Reporter) loop() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, r.opts.BatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		r.send(batch)
+		batch = batch[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case rec := <-r.queue:
+				batch = append(batch, rec)
+				if len(batch) == r.opts.BatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case rec := <-r.queue:
+			batch = append(batch, rec)
+			if len(batch) == r.opts.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ch := <-r.flushes:
+			drain()
+			close(ch)
+		case <-r.stop:
+			drain()
+			return
+		}
+	}
+}
+
+func (r *Reporter) send(batch []Record) {
+	ok := true
+	for _, s := range r.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.SendTimeout)
+		if err := s.Send(ctx, batch); err != nil {
+			ok = false
+		}
+		cancel()
+	}
+	if ok {
+		r.sent.Add(uint64(len(batch)))
+	} else {
+		r.failed.Add(uint64(len(batch)))
+	}
+}
@@ -0,0 +1,334 @@
+package ierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memSink 记录收到的每一批，gate不为nil时每次Send都等待gate放行
+type memSink struct {
+	mu      sync.Mutex
+	batches [][]Record
+	closed  bool
+
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func (s *memSink) Send(ctx context.Context, records []Record) error {
+	if s.gate != nil {
+		s.entered <- struct{}{}
+		select {
+		case <-s.gate:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]Record(nil), records...))
+	return nil
+}
+
+func (s *memSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// msgs 按收到的顺序返回各条Record的Msg
+func (s *memSink) msgs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, b := range s.batches {
+		for _, r := range b {
+			out = append(out, r.Msg)
+		}
+	}
+	return out
+}
+
+func (s *memSink) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []int
+	for _, b := range s.batches {
+		out = append(out, len(b))
+	}
+	return out
+}
+
+func newGatedSink() *memSink {
+	return &memSink{gate: make(chan struct{}), entered: make(chan struct{}, 16)}
+}
+
+func TestReporterBatching(t *testing.T) {
+	sink := &memSink{}
+	r := NewReporter(ReporterOptions{BatchSize: 3, FlushInterval: time.Hour}, sink)
+	defer r.Close(context.Background())
+	for i := 0; i < 7; i++ {
+		if !r.Report(context.Background(), errors.New(fmt.Sprint(i))) {
+			t.Fatalf("report %d rejected", i)
+		}
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(sink.sizes()); got != "[3 3 1]" {
+		t.Errorf("batch sizes = %s, want [3 3 1]", got)
+	}
+	if got := strings.Join(sink.msgs(), ","); got != "0,1,2,3,4,5,6" {
+		t.Errorf("records = %s", got)
+	}
+	if st := r.Stats(); st.Reported != 7 || st.Sent != 7 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+// fillQueue 让后台协程阻塞在第一条的发送上，再用两条填满容量为2的队列
+func fillQueue(t *testing.T, r *Reporter, sink *memSink) {
+	t.Helper()
+	r.Report(context.Background(), errors.New("1"))
+	<-sink.entered
+	for _, msg := range []string{"2", "3"} {
+		if !r.Report(context.Background(), errors.New(msg)) {
+			t.Fatalf("report %s rejected", msg)
+		}
+	}
+}
+
+func TestReporterDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy DropPolicy
+		ok     bool
+		want   string
+	}{
+		{DropNewest, false, "1,2,3"},
+		{DropOldest, true, "1,3,4"},
+	}
+	for _, tt := range tests {
+		sink := newGatedSink()
+		r := NewReporter(ReporterOptions{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour, Policy: tt.policy}, sink)
+		fillQueue(t, r, sink)
+		if ok := r.Report(context.Background(), errors.New("4")); ok != tt.ok {
+			t.Errorf("policy %d: report on full queue = %v, want %v", tt.policy, ok, tt.ok)
+		}
+		close(sink.gate)
+		if err := r.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(sink.msgs(), ","); got != tt.want {
+			t.Errorf("policy %d: records = %s, want %s", tt.policy, got, tt.want)
+		}
+		if st := r.Stats(); st.Dropped != 1 {
+			t.Errorf("policy %d: dropped = %d, want 1", tt.policy, st.Dropped)
+		}
+	}
+}
+
+func TestReporterBlock(t *testing.T) {
+	sink := newGatedSink()
+	r := NewReporter(ReporterOptions{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour, Policy: Block}, sink)
+	fillQueue(t, r, sink)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if r.Report(ctx, errors.New("timeout")) {
+		t.Error("report on full queue succeeded before ctx expired")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(sink.gate)
+	}()
+	if !r.Report(context.Background(), errors.New("4")) {
+		t.Error("blocked report was not enqueued once the queue drained")
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(sink.msgs(), ","); got != "1,2,3,4" {
+		t.Errorf("records = %s", got)
+	}
+}
+
+func TestReporterCloseDrains(t *testing.T) {
+	sink := &memSink{}
+	r := NewReporter(ReporterOptions{FlushInterval: time.Hour}, sink)
+	for i := 0; i < 5; i++ {
+		r.Report(context.Background(), errors.New(fmt.Sprint(i)))
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(sink.msgs()); got != 5 {
+		t.Errorf("sent %d records, want 5", got)
+	}
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+	if r.Report(context.Background(), errors.New("late")) {
+		t.Error("report after Close succeeded")
+	}
+	if err := r.Flush(context.Background()); err != ErrReporterClosed {
+		t.Errorf("Flush after Close = %v, want ErrReporterClosed", err)
+	}
+}
+
+// 与Close并发的Report要么被拒绝，要么一定会被发送
+func TestReporterCloseRace(t *testing.T) {
+	for _, policy := range []DropPolicy{DropNewest, DropOldest, Block} {
+		sink := &memSink{}
+		r := NewReporter(ReporterOptions{QueueSize: 8, BatchSize: 4, FlushInterval: time.Hour, Policy: policy}, sink)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					r.Report(context.Background(), errors.New("x"))
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		if err := r.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		st := r.Stats()
+		got := uint64(len(sink.msgs()))
+		// DropOldest丢弃的旧错误已经计入了Reported，其他策略入队的错误都应该被发送
+		lost := st.Reported - got
+		if got > st.Reported || (policy != DropOldest && lost != 0) || lost > st.Dropped {
+			t.Errorf("policy %d: reported %d, dropped %d, but sink received %d", policy, st.Reported, st.Dropped, got)
+		}
+	}
+}
+
+func TestReporterSendTimeout(t *testing.T) {
+	sink := newGatedSink()
+	r := NewReporter(ReporterOptions{BatchSize: 1, FlushInterval: time.Hour, SendTimeout: 20 * time.Millisecond}, sink)
+	r.Report(context.Background(), errors.New("stuck"))
+	<-sink.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("Close with a stuck sink: %v", err)
+	}
+	if st := r.Stats(); st.Failed != 1 {
+		t.Errorf("failed = %d, want 1", st.Failed)
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.log")
+	rec := NewRecord(errors.New("boom"), false)
+	line, _ := json.Marshal(rec)
+	s, err := NewFileSink(path, int64(len(line)+1)*2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个文件放得下两条，写7条后产生 path、path.1、path.2，最早的两条随第四个文件被丢弃
+	for i := 0; i < 7; i++ {
+		if err := s.Send(context.Background(), []Record{rec}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, lines := range map[string]int{path: 1, path + ".1": 2, path + ".2": 2} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(b), "\n"); got != lines {
+			t.Errorf("%s has %d lines, want %d", filepath.Base(name), got, lines)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 should not exist: %v", path, err)
+	}
+	if err := s.Send(context.Background(), []Record{rec}); err != os.ErrClosed {
+		t.Errorf("Send after Close = %v, want os.ErrClosed", err)
+	}
+}
+
+func TestFileSinkRotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.log")
+	rec := NewRecord(errors.New("boom"), false)
+	line, _ := json.Marshal(rec)
+	s, err := NewFileSink(path, int64(len(line)+1)*2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if err := s.Send(ctx, []Record{rec, rec}); err != nil {
+		t.Fatal(err)
+	}
+	// path.1 是非空目录时轮转的重命名失败
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(ctx, []Record{rec}); err == nil {
+		t.Fatal("Send succeeded although the rotation failed")
+	}
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(ctx, []Record{rec}); err != nil {
+		t.Fatalf("Send after a failed rotation: %v", err)
+	}
+	for name, lines := range map[string]int{path: 1, path + ".1": 2} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(b), "\n"); got != lines {
+			t.Errorf("%s has %d lines, want %d", filepath.Base(name), got, lines)
+		}
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusOK
+	var got []Record
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header.Get("X-Token")
+		got = nil
+		_ = json.NewDecoder(req.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	s := NewWebhookSink(srv.URL)
+	s.Header = http.Header{"X-Token": {"secret"}}
+	records := []Record{NewRecord(errors.New("a"), false), NewRecord(errors.New("b"), false)}
+	if err := s.Send(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Msg != "b" || header != "secret" {
+		t.Errorf("server received %d records, header %q", len(got), header)
+	}
+
+	status = http.StatusBadGateway
+	err := s.Send(context.Background(), records)
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("Send on 502 = %v, want an error with the status", err)
+	}
+}
@@ -0,0 +1,36 @@
+package ierror
+
+import (
+	"context"
+	"runtime/trace"
+	"strconv"
+	"sync/atomic"
+)
+
+var traceEvents atomic.Bool
+
+// EnableTraceEvents 开启后，IError被创建或上报时会写入runtime/trace的日志事件，
+// 在 go tool trace 的时间线上可以看到错误出现的位置
+// 事件的category为 ierror/<code>，使用带ctx的构造函数时事件会关联到ctx中的trace任务
+// 只有在trace正在采集时才会产生开销
+func EnableTraceEvents(on bool) {
+	traceEvents.Store(on)
+}
+
+func traceCreated(ctx context.Context, x *IError) {
+	if !traceEvents.Load() || !trace.IsEnabled() {
+		return
+	}
+	trace.Log(ctx, traceCategory(x.Code), "created: "+x.Msg)
+}
+
+func traceReported(ctx context.Context, err error) {
+	if !traceEvents.Load() || !trace.IsEnabled() {
+		return
+	}
+	trace.Log(ctx, traceCategory(int(GetErrorCode(err))), "reported: "+err.Error())
+}
+
+func traceCategory(code int) string {
+	return "ierror/" + strconv.Itoa(code)
+}
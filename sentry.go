@@ -0,0 +1,257 @@
+package ierror
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SentryFrame Sentry事件中的一帧
+type SentryFrame struct {
+	Function string `json:"function,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	AbsPath  string `json:"abs_path,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+	InApp    bool   `json:"in_app"`
+}
+
+// SentryStacktrace Sentry的调用栈，帧按调用顺序排列，最后一帧离出错位置最近
+type SentryStacktrace struct {
+	Frames []SentryFrame `json:"frames"`
+}
+
+// SentryMechanism 用于描述异常链中各层的父子关系
+type SentryMechanism struct {
+	Type             string `json:"type"`
+	IsExceptionGroup bool   `json:"is_exception_group,omitempty"`
+	ExceptionID      int    `json:"exception_id"`
+	ParentID         *int   `json:"parent_id,omitempty"`
+}
+
+// SentryException 异常链中的一层
+type SentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Module     string            `json:"module,omitempty"`
+	Stacktrace *SentryStacktrace `json:"stacktrace,omitempty"`
+	Mechanism  *SentryMechanism  `json:"mechanism,omitempty"`
+}
+
+// SentryEvent Sentry的事件结构，只包含ierror用到的字段
+type SentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+	Exception   struct {
+		Values []SentryException `json:"values"`
+	} `json:"exception"`
+}
+
+// SentryClient 将错误以Sentry envelope格式发送到Sentry或兼容的服务
+type SentryClient struct {
+	DSN         string
+	Release     string
+	Environment string
+	ServerName  string
+	// Tags 附加到每个事件上的固定标签
+	Tags map[string]string
+	// InAppPrefixes 属于业务代码的包路径前缀，默认为主模块路径
+	InAppPrefixes []string
+	Client        *http.Client
+
+	endpoint  string
+	publicKey string
+}
+
+// NewSentryClient 解析dsn并创建客户端，dsn形如 https://<key>@<host>/<project>
+func NewSentryClient(dsn string) (*SentryClient, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("ierror: sentry dsn %q has no public key", dsn)
+	}
+	i := strings.LastIndex(u.Path, "/")
+	project := u.Path[i+1:]
+	if project == "" {
+		return nil, fmt.Errorf("ierror: sentry dsn %q has no project id", dsn)
+	}
+	c := &SentryClient{
+		DSN:       dsn,
+		publicKey: u.User.Username(),
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/",
+			u.Scheme, u.Host, u.Path[:i], project),
+	}
+	c.ServerName, _ = os.Hostname()
+	if b := GetBuildInfo(); b.Module != "" {
+		c.InAppPrefixes = []string{b.Module}
+		c.Release = b.Revision
+		if c.Release == "" && b.Version != "(devel)" {
+			c.Release = b.Version
+		}
+	}
+	return c, nil
+}
+
+// Event 将err转换为Sentry事件，每一层对应一个exception，最内层排在最前面
+func (c *SentryClient) Event(err error) *SentryEvent {
+	ev := &SentryEvent{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		Logger:      "ierror",
+		Release:     c.Release,
+		Environment: c.Environment,
+		ServerName:  c.ServerName,
+		Message:     err.Error(),
+		Tags:        map[string]string{},
+		Fingerprint: []string{Fingerprint(err)},
+	}
+	for k, v := range c.Tags {
+		ev.Tags[k] = v
+	}
+	ev.Tags["ierror.code"] = strconv.Itoa(int(GetErrorCode(err)))
+
+	var (
+		values []SentryException
+		codes  []string
+	)
+	for e := err; e != nil; {
+		ge, ok := e.(*IError)
+		if !ok {
+			values = append(values, SentryException{
+				Type:  fmt.Sprintf("%T", e),
+				Value: e.Error(),
+			})
+			e = errors.Unwrap(e)
+			continue
+		}
+		codes = append(codes, strconv.Itoa(ge.Code))
+		ex := SentryException{
+			Type:  fmt.Sprintf("IError(%d)", ge.Code),
+			Value: ge.Msg,
+		}
+		if frames := layerFrames(ge); len(frames) > 0 {
+			ex.Module = FuncPackage(frames[0].Function)
+			ex.Stacktrace = c.stacktrace(frames)
+		}
+		values = append(values, ex)
+		e = ge.Err
+	}
+	ev.Tags["ierror.codes"] = strings.Join(codes, ",")
+
+	// values当前是从外到内，Sentry要求最内层的原因排在最前面
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	for i := range values {
+		id := len(values) - 1 - i
+		m := &SentryMechanism{Type: "chained", ExceptionID: id}
+		if id > 0 {
+			parent := id - 1
+			m.ParentID = &parent
+		}
+		values[i].Mechanism = m
+	}
+	ev.Exception.Values = values
+	return ev
+}
+
+func (c *SentryClient) stacktrace(frames []runtime.Frame) *SentryStacktrace {
+	st := &SentryStacktrace{Frames: make([]SentryFrame, 0, len(frames))}
+	// runtime的顺序是从出错位置往外，Sentry要求相反的顺序
+	for i := len(frames) - 1; i >= 0; i-- {
+		f := frames[i]
+		module := FuncPackage(f.Function)
+		st.Frames = append(st.Frames, SentryFrame{
+			Function: strings.TrimPrefix(f.Function, module+"."),
+			Module:   module,
+			Filename: f.File[strings.LastIndex(f.File, "/")+1:],
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    inAppPackage(module, c.InAppPrefixes...),
+		})
+	}
+	return st
+}
+
+// Envelope 将事件编码为Sentry envelope
+func (c *SentryClient) Envelope(ev *SentryEvent) ([]byte, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": ev.EventID,
+		"dsn":      c.DSN,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Write(header)
+	fmt.Fprintf(&buf, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Capture 将err转换为事件并发送，返回事件ID
+func (c *SentryClient) Capture(ctx context.Context, err error) (string, error) {
+	ev := c.Event(err)
+	body, e := c.Envelope(ev)
+	if e != nil {
+		return "", e
+	}
+	req, e := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if e != nil {
+		return "", e
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf(
+		"Sentry sentry_version=7, sentry_client=ierror/1.0, sentry_key=%s", c.publicKey))
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, e := client.Do(req)
+	if e != nil {
+		return "", e
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("ierror: sentry %s: %s", c.endpoint, resp.Status)
+	}
+	return ev.EventID, nil
+}
+
+func newEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
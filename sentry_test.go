@@ -0,0 +1,144 @@
+package ierror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func sentryInner() error { return WrapIError(errors.New("disk full"), 10, "write failed") }
+
+func sentryOuter() error { return WrapIError(sentryInner(), 20, "save failed") }
+
+func TestSentryCapture(t *testing.T) {
+	var (
+		path, auth, ctype string
+		body              []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth, ctype = r.URL.Path, r.Header.Get("X-Sentry-Auth"), r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "://", "://pubkey@", 1) + "/42"
+	c, err := NewSentryClient(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.InAppPrefixes = []string{"github.com/RanFeng/ierror"}
+	id, err := c.Capture(context.Background(), sentryOuter())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/api/42/envelope/" {
+		t.Errorf("path = %q", path)
+	}
+	if ctype != "application/x-sentry-envelope" {
+		t.Errorf("content type = %q", ctype)
+	}
+	if !strings.HasPrefix(auth, "Sentry sentry_version=7,") || !strings.Contains(auth, "sentry_key=pubkey") {
+		t.Errorf("X-Sentry-Auth = %q", auth)
+	}
+
+	// envelope：头部、条目头部、事件，各占一行
+	lines := bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n"))
+	if len(lines) != 3 {
+		t.Fatalf("envelope has %d lines, want 3:\n%s", len(lines), body)
+	}
+	var header struct {
+		EventID string `json:"event_id"`
+		DSN     string `json:"dsn"`
+	}
+	if err := json.Unmarshal(lines[0], &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.EventID != id || header.DSN != dsn {
+		t.Errorf("envelope header = %s", lines[0])
+	}
+	var item struct {
+		Type   string `json:"type"`
+		Length int    `json:"length"`
+	}
+	if err := json.Unmarshal(lines[1], &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Type != "event" || item.Length != len(lines[2]) {
+		t.Errorf("item header = %s, payload length %d", lines[1], len(lines[2]))
+	}
+
+	var ev SentryEvent
+	if err := json.Unmarshal(lines[2], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.EventID != id || ev.Tags["ierror.code"] != "20" || ev.Tags["ierror.codes"] != "20,10" {
+		t.Errorf("event id %q tags %v", ev.EventID, ev.Tags)
+	}
+
+	// 最内层的原因在前，exception_id从最外层的0开始，内层以parent_id指向外层
+	values := ev.Exception.Values
+	wantTypes := []string{"*errors.errorString", "IError(10)", "IError(20)"}
+	if len(values) != len(wantTypes) {
+		t.Fatalf("got %d exceptions, want %d", len(values), len(wantTypes))
+	}
+	for i, ex := range values {
+		if ex.Type != wantTypes[i] {
+			t.Errorf("exception %d type = %q, want %q", i, ex.Type, wantTypes[i])
+		}
+		wantID := len(values) - 1 - i
+		m := ex.Mechanism
+		if m == nil || m.Type != "chained" || m.ExceptionID != wantID {
+			t.Fatalf("exception %d mechanism = %+v, want id %d", i, m, wantID)
+		}
+		switch {
+		case wantID == 0 && m.ParentID != nil:
+			t.Errorf("outermost exception has parent %d", *m.ParentID)
+		case wantID > 0 && (m.ParentID == nil || *m.ParentID != wantID-1):
+			t.Errorf("exception %d parent = %v, want %d", i, m.ParentID, wantID-1)
+		}
+	}
+	if values[0].Stacktrace != nil {
+		t.Error("foreign error has a stacktrace")
+	}
+
+	// Sentry的帧从外到内，最后一帧是创建位置
+	frames := values[1].Stacktrace.Frames
+	last := frames[len(frames)-1]
+	if last.Function != "sentryInner" || last.Module != "github.com/RanFeng/ierror" || !last.InApp {
+		t.Errorf("creation frame = %+v", last)
+	}
+	for _, f := range values[2].Stacktrace.Frames {
+		if f.Module == "testing" && f.InApp {
+			t.Errorf("frame %s.%s marked in_app", f.Module, f.Function)
+		}
+	}
+}
+
+func TestSentryCaptureError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	c, err := NewSentryClient(strings.Replace(srv.URL, "://", "://k@", 1) + "/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Capture(context.Background(), sentryOuter()); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Capture on 429 = %v", err)
+	}
+}
+
+func TestNewSentryClientInvalid(t *testing.T) {
+	for _, dsn := range []string{"https://sentry.io/1", "https://key@sentry.io/"} {
+		if _, err := NewSentryClient(dsn); err == nil {
+			t.Errorf("NewSentryClient(%q) succeeded", dsn)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package ierror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// WriterSink 将Record逐行以JSON写入io.Writer
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink 创建写入w的JSON-lines Sink
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// NewStdoutSink 创建写入标准输出的JSON-lines Sink
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+// Send 实现Sink
+func (s *WriterSink) Send(_ context.Context, records []Record) error {
+	b, err := encodeLines(records)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+// FileSink 将Record逐行以JSON写入文件，文件超过MaxBytes时轮转
+// 轮转后的文件依次命名为 path.1, path.2 ... 最多保留MaxBackups个
+type FileSink struct {
+	Path       string
+	MaxBytes   int64
+	MaxBackups int
+
+	mu sync.Mutex
+	// f 轮转失败后为nil，下一次Send时重新打开
+	f      *os.File
+	size   int64
+	closed bool
+}
+
+// NewFileSink 打开（或创建）path并返回FileSink，maxBytes<=0表示不轮转
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{Path: path, MaxBytes: maxBytes, MaxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return err
+	}
+	if s.MaxBackups <= 0 {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return s.open()
+	}
+	for i := s.MaxBackups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1))
+	}
+	if err := os.Rename(s.Path, s.Path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.open()
+}
+
+// Send 实现Sink
+func (s *FileSink) Send(_ context.Context, records []Record) error {
+	b, err := encodeLines(records)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if s.f == nil {
+		if err = s.open(); err != nil {
+			return err
+		}
+	}
+	if s.MaxBytes > 0 && s.size > 0 && s.size+int64(len(b)) > s.MaxBytes {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// Close 关闭文件
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// WebhookSink 将一批Record以JSON数组POST到URL
+type WebhookSink struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+// NewWebhookSink 创建POST到url的Sink，使用http.DefaultClient
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url}
+}
+
+// Send 实现Sink，非2xx的响应视为失败
+func (s *WebhookSink) Send(ctx context.Context, records []Record) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ierror: webhook %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+func encodeLines(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range records {
+		if err := enc.Encode(&records[i]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,14 @@
+//go:build go1.21
+
+package ierror
+
+import "log/slog"
+
+// LogValue 实现slog.LogValuer，使TextHandler、JSONHandler等所有Handler都输出错误信息、错误码和指纹
+func (x *IError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("msg", x.Error()),
+		slog.Int("code", int(GetErrorCode(x))),
+		slog.String("fingerprint", Fingerprint(x)),
+	)
+}
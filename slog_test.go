@@ -0,0 +1,39 @@
+//go:build go1.21
+
+package ierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogValue(t *testing.T) {
+	err := WrapIError(NewIError(1, "inner"), 2, "outer")
+	fp := Fingerprint(err)
+
+	var text bytes.Buffer
+	slog.New(slog.NewTextHandler(&text, nil)).Error("failed", "err", err)
+	for _, want := range []string{`err.msg="inner: outer"`, "err.code=2", "err.fingerprint=" + fp} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output %q does not contain %q", text.String(), want)
+		}
+	}
+
+	var js bytes.Buffer
+	slog.New(slog.NewJSONHandler(&js, nil)).Error("failed", "err", err)
+	var v struct {
+		Err struct {
+			Code        int    `json:"code"`
+			Fingerprint string `json:"fingerprint"`
+		} `json:"err"`
+	}
+	if e := json.Unmarshal(js.Bytes(), &v); e != nil {
+		t.Fatal(e)
+	}
+	if v.Err.Code != 2 || v.Err.Fingerprint != fp {
+		t.Errorf("json output %s: got code %d fingerprint %q", js.String(), v.Err.Code, v.Err.Fingerprint)
+	}
+}
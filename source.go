@@ -0,0 +1,56 @@
+package ierror
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// sourceCache 按文件缓存读取到的源码行，读取失败的文件缓存为nil
+var sourceCache = struct {
+	sync.Mutex
+	files map[string][]string
+}{files: map[string][]string{}}
+
+// sourceLines 返回file的所有行，文件不存在或无法读取时返回nil
+func sourceLines(file string) []string {
+	sourceCache.Lock()
+	defer sourceCache.Unlock()
+	if lines, ok := sourceCache.files[file]; ok {
+		return lines
+	}
+	var lines []string
+	if b, err := os.ReadFile(file); err == nil {
+		lines = strings.Split(string(bytes.TrimRight(b, "\n")), "\n")
+	}
+	sourceCache.files[file] = lines
+	return lines
+}
+
+// sourceSnippet 返回line前后context行的源码，出错的行以 > 标记
+// 文件读取不到或行号越界时返回空字符串
+func sourceSnippet(file string, line, context int) string {
+	lines := sourceLines(file)
+	if line <= 0 || line > len(lines) {
+		return ""
+	}
+	from, to := line-context, line+context
+	if from < 1 {
+		from = 1
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	width := len(fmt.Sprint(to))
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		mark := " "
+		if i == line {
+			mark = ">"
+		}
+		fmt.Fprintf(&sb, "\n\t%s %*d | %s", mark, width, i, strings.TrimRight(lines[i-1], "\r"))
+	}
+	return sb.String()
+}
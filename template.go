@@ -0,0 +1,202 @@
+package ierror
+
+import (
+	"text/template"
+	"time"
+)
+
+// TraceTemplate 自定义Trace的布局，分别用于头部、每一层和每一帧
+// 任一模板为nil时跳过对应的部分，各层的顺序与Trace一致：最内层在前
+type TraceTemplate struct {
+	// Header 执行时的数据为TemplateHeader
+	Header *template.Template
+	// Layer 执行时的数据为TemplateLayer，在该层的各帧之前输出
+	Layer *template.Template
+	// Frame 执行时的数据为TemplateFrame
+	Frame *template.Template
+}
+
+// TemplateHeader Header模板的数据
+type TemplateHeader struct {
+	Error       string
+	Code        int32
+	Fingerprint string
+	Build       BuildInfo
+	Breadcrumbs []Crumb
+}
+
+// TemplateLayer Layer模板的数据
+type TemplateLayer struct {
+	Index   int
+	Code    int
+	Msg     string
+	Foreign bool
+	// NoStack 没有调用栈的IError层，例如哨兵错误
+	NoStack bool
+	// Time 未开启EnableTimestamps时为零值
+	Time      time.Time
+	Goroutine uint64
+	Elapsed   time.Duration
+	// Header 与默认格式中每层第一帧上的 msg: ..., code: ... 相同
+	Header string
+	// OmittedLayers 按TraceLimits.MaxLayers在这一层之前省略的层数
+	OmittedLayers int
+	// OmittedFrames 按TraceLimits.MaxFrames在这一层的最后一帧之后省略的帧数
+	OmittedFrames int
+}
+
+// TemplateFrame Frame模板的数据
+type TemplateFrame struct {
+	Layer         *TemplateLayer
+	First         bool // 是否为该层的第一帧，即错误被创建的位置
+	Last          bool // 是否为该层输出的最后一帧
+	Function      string
+	ShortFunction string
+	Package       string
+	File          string // 按PathMode改写后的路径
+	AbsFile       string
+	Line          int
+	Location      string // 文件:行号，带有按选项生成的链接
+	InApp         bool
+	Repeat        int
+}
+
+// NewTraceTemplate 解析三段模板文本，空字符串表示不输出对应的部分
+func NewTraceTemplate(header, layer, frame string) (*TraceTemplate, error) {
+	t := &TraceTemplate{}
+	for _, p := range []struct {
+		dst  **template.Template
+		name string
+		text string
+	}{
+		{&t.Header, "header", header},
+		{&t.Layer, "layer", layer},
+		{&t.Frame, "frame", frame},
+	} {
+		if p.text == "" {
+			continue
+		}
+		tmpl, err := template.New(p.name).Parse(p.text)
+		if err != nil {
+			return nil, err
+		}
+		*p.dst = tmpl
+	}
+	return t, nil
+}
+
+// MustTraceTemplate 与NewTraceTemplate相同，解析失败时panic
+func MustTraceTemplate(header, layer, frame string) *TraceTemplate {
+	t, err := NewTraceTemplate(header, layer, frame)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// 内置的模板
+var (
+	// TemplateClassic 与默认格式相同的布局
+	TemplateClassic = MustTraceTemplate("",
+		`{{if .OmittedLayers}}
+... {{.OmittedLayers}} layers omitted ...{{end}}{{if .Foreign}}
+not.found : {{.Msg}}
+	/can/not/get/trace/info/:sorry{{else if .NoStack}}
+no.stack : [{{.Header}}]
+	/can/not/get/trace/info/:sorry{{end}}`,
+		`
+{{.ShortFunction}} : [{{if .First}}{{.Layer.Header}}{{end}}]{{if gt .Repeat 1}} x{{.Repeat}}{{end}}
+	{{.Location}}{{if and .Last .Layer.OmittedFrames}}
+	... {{.Layer.OmittedFrames}} frames omitted ...{{end}}`)
+
+	// TemplateIndented 每层一个标题，帧缩进列在下方
+	TemplateIndented = MustTraceTemplate(`{{.Error}}`,
+		`{{if .OmittedLayers}}
+... {{.OmittedLayers}} layers omitted ...{{end}}
+{{if .Foreign}}error: {{.Msg}}{{else}}{{if .Code}}[{{.Code}}] {{end}}{{.Msg}}{{end}}`,
+		`
+    {{.ShortFunction}}{{if gt .Repeat 1}} x{{.Repeat}}{{end}}
+        {{.Location}}{{if and .Last .Layer.OmittedFrames}}
+    ... {{.Layer.OmittedFrames}} frames omitted ...{{end}}`)
+
+	// TemplateGoroutine 类似Go panic时的调用栈格式
+	TemplateGoroutine = MustTraceTemplate("",
+		`{{if .Index}}
+
+{{end}}{{if .OmittedLayers}}... {{.OmittedLayers}} layers omitted ...
+
+{{end}}{{if .Foreign}}error: {{.Msg}}{{else}}ierror {{.Code}}: {{.Msg}}{{end}}`,
+		`
+{{.Function}}(...)
+	{{.Location}}{{if and .Last .Layer.OmittedFrames}}
+...{{.Layer.OmittedFrames}} frames elided...{{end}}`)
+)
+
+func traceTemplate(tw *traceWriter, src traceSource, opts TraceOptions) {
+	t := opts.Template
+	if t.Header != nil {
+		if tw.err == nil {
+			msg, code, fingerprint := src.summary()
+			h := TemplateHeader{
+				Error:       msg,
+				Code:        code,
+				Fingerprint: fingerprint,
+				Breadcrumbs: src.breadcrumbs(),
+			}
+			if src.build {
+				h.Build = GetBuildInfo()
+			}
+			tw.err = t.Header.Execute(tw, h)
+		}
+	}
+	head, tail := opts.Limits.split(len(src.layers))
+	for i, l := range src.layers {
+		if i >= head && i < len(src.layers)-tail {
+			continue
+		}
+		frames, omitted := opts.Limits.frames(opts.Filter.apply(l.Frames))
+		tl := &TemplateLayer{
+			Index:         i,
+			Code:          l.Code,
+			Msg:           l.Msg,
+			Foreign:       l.Foreign,
+			NoStack:       !l.Foreign && len(l.Frames) == 0,
+			Goroutine:     l.Goroutine,
+			Elapsed:       l.Elapsed,
+			Header:        layerHeader(l),
+			OmittedFrames: omitted,
+		}
+		if i == len(src.layers)-tail && tail > 0 {
+			tl.OmittedLayers = len(src.layers) - head - tail
+		}
+		if l.Time != nil {
+			tl.Time = *l.Time
+		}
+		if t.Layer != nil && tw.err == nil {
+			tw.err = t.Layer.Execute(tw, tl)
+		}
+		if t.Frame == nil {
+			continue
+		}
+		for j, f := range frames {
+			if tw.err != nil {
+				return
+			}
+			rel := opts.moduleRel(f.Frame)
+			tw.err = t.Frame.Execute(tw, TemplateFrame{
+				Layer:         tl,
+				First:         j == 0,
+				Last:          j == len(frames)-1,
+				Function:      f.Function,
+				ShortFunction: f.ShortFunction(),
+				Package:       f.Package,
+				File:          opts.file(f.Frame, rel),
+				AbsFile:       f.File,
+				Line:          f.Line,
+				Location:      opts.location(f.Frame),
+				InApp:         f.InApp,
+				Repeat:        f.Repeat,
+			})
+		}
+	}
+}
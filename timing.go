@@ -0,0 +1,61 @@
+package ierror
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var timestamps atomic.Bool
+
+// EnableTimestamps 开启后每一层IError都会记录创建时间和所在的goroutine ID，
+// Trace中会展示各层之间经过的时间，便于排查异步传递的错误
+// 获取goroutine ID需要调用runtime.Stack，默认关闭
+func EnableTimestamps(on bool) {
+	timestamps.Store(on)
+}
+
+// Time 返回这一层被创建的时间（带单调时钟），未开启EnableTimestamps时为零值
+func (x *IError) Time() time.Time {
+	return x.at
+}
+
+// Goroutine 返回创建这一层的goroutine ID，未开启EnableTimestamps时为0
+func (x *IError) Goroutine() uint64 {
+	return x.gid
+}
+
+func (x *IError) stamp() {
+	if !timestamps.Load() {
+		return
+	}
+	x.at = time.Now()
+	x.gid = goroutineID()
+}
+
+// traceTiming Trace中一层的时间信息
+func traceTiming(l Layer) string {
+	if l.Time == nil {
+		return ""
+	}
+	str := fmt.Sprintf(", time: %s, goroutine: %d", l.Time.Format("15:04:05.000000"), l.Goroutine)
+	if l.Elapsed != 0 {
+		str += fmt.Sprintf(", elapsed: %s", l.Elapsed)
+	}
+	return str
+}
+
+// goroutineID 从runtime.Stack的第一行 "goroutine 123 [running]:" 中解析出ID
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
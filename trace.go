@@ -0,0 +1,388 @@
+package ierror
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// PathMode Trace中文件路径的展示方式
+type PathMode int
+
+const (
+	// PathAbsolute 原样展示构建机器上的绝对路径
+	PathAbsolute PathMode = iota
+	// PathRelative 主模块内的文件展示为相对模块根目录的路径，
+	// 依赖展示为 模块@版本/文件，标准库和GOPATH中的包展示为 包路径/文件
+	PathRelative
+)
+
+// LinkMode Trace中为每一帧附加的链接类型
+type LinkMode int
+
+const (
+	// LinkNone 不附加链接
+	LinkNone LinkMode = iota
+	// LinkRepo 主模块内的帧链接到代码仓库的网页，需要设置RepoURL
+	LinkRepo
+	// LinkVSCode vscode://file/... 编辑器链接
+	LinkVSCode
+	// LinkGoLand goland://open?... 编辑器链接
+	LinkGoLand
+)
+
+// TraceOptions Trace的输出选项，零值即为默认的输出格式
+type TraceOptions struct {
+	Format TraceFormat
+	// Template 不为nil时使用自定义模板输出，忽略Format
+	Template *TraceTemplate
+
+	Paths PathMode
+	// ModuleRoot 主模块在构建机器上的根目录，设置后main包等文件也能改写为相对路径
+	ModuleRoot string
+
+	Links LinkMode
+	// RepoURL 仓库网页链接的模板，支持 {rev} {path} {line} 占位符，参见GitHubURL、GitLabURL
+	RepoURL string
+	// Revision 仓库链接使用的版本，为空时使用构建信息中的vcs.revision，再为空时使用HEAD
+	Revision string
+	// Hyperlinks 为true时以OSC 8终端超链接的形式输出链接，否则将链接附在位置之后
+	Hyperlinks bool
+
+	// Filter 帧的过滤和折叠规则
+	Filter FrameFilter
+
+	// Limits 输出大小的限制，FormatRaw不受限制，MaxBytes对自定义模板不生效
+	Limits TraceLimits
+
+	// Color 默认格式下是否使用ANSI颜色：错误码红色、信息加粗、业务代码的帧高亮、依赖库的帧变暗
+	Color ColorMode
+
+	// SourceLines 大于0时在每一帧下方展示出错行前后各SourceLines行源码，
+	// 源码从本地磁盘读取，适合本地开发时使用，读取不到的文件直接跳过
+	SourceLines int
+}
+
+// DefaultTraceOptions Trace使用的选项，应在程序启动时设置
+var DefaultTraceOptions TraceOptions
+
+// GitHubURL 返回GitHub仓库的链接模板，repo形如 owner/name
+func GitHubURL(repo string) string {
+	return "https://github.com/" + repo + "/blob/{rev}/{path}#L{line}"
+}
+
+// GitLabURL 返回GitLab仓库的链接模板，base形如 https://gitlab.com/owner/name
+func GitLabURL(base string) string {
+	return strings.TrimSuffix(base, "/") + "/-/blob/{rev}/{path}#L{line}"
+}
+
+// TraceWith 使用指定的选项输出err的调用栈
+func TraceWith(err error, opts TraceOptions) string {
+	var sb strings.Builder
+	_ = WriteTrace(&sb, err, opts)
+	return sb.String()
+}
+
+// WriteTrace 将err的调用栈按选项流式写入w，只遍历一遍错误链，返回写入时遇到的第一个错误
+func WriteTrace(w io.Writer, err error, opts TraceOptions) error {
+	tw := &traceWriter{w: bufio.NewWriter(w), color: palette(opts.colorEnabled(w))}
+	ge, ok := err.(*IError)
+	if !ok {
+		tw.WriteString(err.Error())
+		return tw.flush()
+	}
+	if opts.Format == FormatRaw && opts.Template == nil {
+		traceRaw(tw, ge)
+		return tw.flush()
+	}
+	writeTrace(tw, traceSource{ge: ge, sum: &traceSummary{}, layers: Layers(ge), build: true}, opts)
+	return tw.flush()
+}
+
+// WriteLayers 将结构化的各层按选项写入w，各层的顺序与Layers一致：最内层在前
+// 用于输出ParseTrace解析出的或从日志中还原的错误，
+// 错误信息、错误码和指纹由各层推算，与GetErrorCode一样错误码取最外层IError的错误码，
+// 不附带当前进程的部署信息
+func WriteLayers(w io.Writer, layers []Layer, opts TraceOptions) error {
+	tw := &traceWriter{w: bufio.NewWriter(w), color: palette(opts.colorEnabled(w))}
+	sum := &traceSummary{code: ErrUnknown, fingerprint: LayersFingerprint(layers), done: true}
+	msgs := make([]string, 0, len(layers))
+	for _, l := range layers {
+		msgs = append(msgs, l.Msg)
+		if !l.Foreign {
+			sum.code = int32(l.Code)
+		}
+	}
+	sum.err = strings.Join(msgs, gSplitStr)
+	writeTrace(tw, traceSource{sum: sum, layers: layers}, opts)
+	return tw.flush()
+}
+
+// traceSource 输出Trace需要的数据，来自IError或结构化的各层
+type traceSource struct {
+	// ge 来自IError时非nil，错误信息、错误码、指纹和面包屑只在用到它们的格式中按需计算，
+	// 默认格式不调用Error()，避免深的错误链上的二次方开销
+	ge     *IError
+	sum    *traceSummary
+	layers []Layer
+	// build 是否按EnableBuildInfo的设置附带当前进程的部署信息
+	build bool
+}
+
+// traceSummary 整条错误链的错误信息、错误码和指纹，done为false时尚未从ge计算
+type traceSummary struct {
+	err         string
+	code        int32
+	fingerprint string
+	done        bool
+}
+
+// summary 返回整条错误链的错误信息、错误码和指纹，来自IError时第一次调用才计算
+func (src traceSource) summary() (string, int32, string) {
+	s := src.sum
+	if !s.done {
+		s.err, s.code, s.fingerprint, s.done = src.ge.Error(), GetErrorCode(src.ge), Fingerprint(src.ge), true
+	}
+	return s.err, s.code, s.fingerprint
+}
+
+// breadcrumbs 返回错误附带的面包屑，结构化的各层没有面包屑
+func (src traceSource) breadcrumbs() []Crumb {
+	if src.ge == nil {
+		return nil
+	}
+	return Breadcrumbs(src.ge)
+}
+
+func writeTrace(tw *traceWriter, src traceSource, opts TraceOptions) {
+	switch {
+	case opts.Template != nil:
+		traceTemplate(tw, src, opts)
+	case opts.Format == FormatCompact:
+		traceOuterFirst(tw, src, opts, traceCompact)
+	case opts.Format == FormatJava:
+		traceOuterFirst(tw, src, opts, traceJava)
+	case opts.Format == FormatJSON:
+		traceOuterFirst(tw, src, opts, traceJSON)
+	case opts.Format == FormatLogfmt:
+		traceOuterFirst(tw, src, opts, traceLogfmt)
+	default:
+		traceText(tw, src, opts)
+	}
+}
+
+// traceText 默认格式
+func traceText(tw *traceWriter, src traceSource, opts TraceOptions) {
+	p := tw.color
+	layers := src.layers
+	head, tail := opts.Limits.split(len(layers))
+	header, crumbs := "", traceCrumbs(src.breadcrumbs())
+	if src.build {
+		header = traceBuildHeader()
+	}
+	tw.WriteString(header)
+	if opts.Limits.MaxBytes <= 0 {
+		for i, l := range layers {
+			if i == head && tail > 0 {
+				tw.WriteString(layersOmitted(len(layers) - head - tail))
+			}
+			if i < head || i >= len(layers)-tail {
+				textLayer(tw, l, opts, p)
+			}
+		}
+		tw.WriteString(crumbs)
+		return
+	}
+
+	// 限制字节数时先渲染必须保留的最内层和最外层，剩余的预算再依次分给中间的层
+	if tail == 0 && head > 1 {
+		head, tail = head-1, 1
+	}
+	var first, outer strings.Builder
+	if head > 0 {
+		textLayer(&first, layers[0], opts, p)
+	}
+	for _, l := range layers[len(layers)-tail:] {
+		textLayer(&outer, l, opts, p)
+	}
+	omitted := len(layers) - head - tail
+	budget := opts.Limits.MaxBytes - len(header) - len(crumbs) - first.Len() - outer.Len() -
+		len(layersOmitted(len(layers)))
+	tw.WriteString(first.String())
+	for i := 1; i < head; i++ {
+		var sb strings.Builder
+		textLayer(&sb, layers[i], opts, p)
+		if sb.Len() > budget {
+			omitted += head - i
+			break
+		}
+		budget -= sb.Len()
+		tw.WriteString(sb.String())
+	}
+	if omitted > 0 {
+		tw.WriteString(layersOmitted(omitted))
+	}
+	tw.WriteString(outer.String())
+	tw.WriteString(crumbs)
+}
+
+// textLayer 以默认格式输出一层
+func textLayer(w io.Writer, l Layer, opts TraceOptions, p palette) {
+	if l.Foreign {
+		_, _ = fmt.Fprintf(w, "\nnot.found : %s\n%s", p.msg(l.Msg), foreignLocation)
+		return
+	}
+	if len(l.Frames) == 0 {
+		// 没有调用栈的IError（例如哨兵错误）也要展示错误信息
+		_, _ = fmt.Fprintf(w, "\n%s : [%s]\n%s", noStack, layerHeaderColor(l, p), foreignLocation)
+		return
+	}
+	frames, omitted := opts.Limits.frames(opts.Filter.apply(l.Frames))
+	for i, f := range frames {
+		var msg []interface{}
+		if i == 0 {
+			msg = append(msg, layerHeaderColor(l, p))
+		}
+		pretty(w, p.frame(f.ShortFunction(), f.InApp), p.frame(opts.location(f.Frame), f.InApp), f.Repeat, msg...)
+		if opts.SourceLines > 0 {
+			_, _ = io.WriteString(w, sourceSnippet(f.File, f.Line, opts.SourceLines))
+		}
+	}
+	if omitted > 0 {
+		_, _ = io.WriteString(w, framesOmitted(omitted))
+	}
+}
+
+// traceWriter 记录第一次写入错误，之后的写入直接忽略
+type traceWriter struct {
+	w     *bufio.Writer
+	err   error
+	color palette
+}
+
+func (t *traceWriter) Write(p []byte) (int, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+	var n int
+	n, t.err = t.w.Write(p)
+	return n, t.err
+}
+
+func (t *traceWriter) WriteString(s string) {
+	if t.err == nil {
+		_, t.err = t.w.WriteString(s)
+	}
+}
+
+func (t *traceWriter) printf(format string, args ...interface{}) {
+	if t.err == nil {
+		_, t.err = fmt.Fprintf(t.w, format, args...)
+	}
+}
+
+func (t *traceWriter) flush() error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.w.Flush()
+}
+
+// layerHeader 每一层第一帧上展示的错误信息
+func layerHeader(l Layer) string {
+	return layerHeaderColor(l, false)
+}
+
+func layerHeaderColor(l Layer, p palette) string {
+	if l.Code == 0 {
+		return fmt.Sprintf("msg: %s%s", p.msg(l.Msg), traceTiming(l))
+	}
+	return fmt.Sprintf("msg: %s, code: %s%s", p.msg(l.Msg), p.code(strconv.Itoa(l.Code)), traceTiming(l))
+}
+
+// location 返回一帧的 文件:行号，按选项改写路径并附加链接
+func (o TraceOptions) location(f Frame) string {
+	rel := o.moduleRel(f)
+	loc := o.file(f, rel) + ":" + strconv.Itoa(f.Line)
+	link := o.link(f, rel)
+	if link == "" {
+		return loc
+	}
+	if o.Hyperlinks {
+		return "\x1b]8;;" + link + "\x1b\\" + loc + "\x1b]8;;\x1b\\"
+	}
+	return loc + " " + link
+}
+
+// file 按PathMode返回展示用的文件路径
+func (o TraceOptions) file(f Frame, rel string) string {
+	if o.Paths == PathRelative {
+		return o.relPath(f, rel)
+	}
+	return f.File
+}
+
+// moduleRel 返回主模块内的文件相对模块根目录的路径，不在主模块内时返回空字符串
+func (o TraceOptions) moduleRel(f Frame) string {
+	if o.ModuleRoot != "" {
+		root := strings.TrimSuffix(o.ModuleRoot, "/") + "/"
+		if strings.HasPrefix(f.File, root) {
+			return f.File[len(root):]
+		}
+	}
+	mod := GetBuildInfo().Module
+	if mod == "" || f.Package == "main" {
+		return ""
+	}
+	if f.Package == mod {
+		return path.Base(f.File)
+	}
+	if strings.HasPrefix(f.Package, mod+"/") {
+		return f.Package[len(mod)+1:] + "/" + path.Base(f.File)
+	}
+	return ""
+}
+
+func (o TraceOptions) relPath(f Frame, rel string) string {
+	if rel != "" {
+		return rel
+	}
+	// 模块缓存中的依赖保留版本号
+	if i := strings.Index(f.File, "/pkg/mod/"); i >= 0 {
+		return f.File[i+len("/pkg/mod/"):]
+	}
+	if f.Package == "main" || f.Package == "" {
+		return path.Base(f.File)
+	}
+	return f.Package + "/" + path.Base(f.File)
+}
+
+func (o TraceOptions) link(f Frame, rel string) string {
+	switch o.Links {
+	case LinkRepo:
+		if o.RepoURL == "" || rel == "" {
+			return ""
+		}
+		rev := o.Revision
+		if rev == "" {
+			rev = GetBuildInfo().Revision
+		}
+		if rev == "" {
+			rev = "HEAD"
+		}
+		return strings.NewReplacer(
+			"{rev}", rev,
+			"{path}", rel,
+			"{line}", strconv.Itoa(f.Line),
+		).Replace(o.RepoURL)
+	case LinkVSCode:
+		return "vscode://file" + f.File + ":" + strconv.Itoa(f.Line)
+	case LinkGoLand:
+		return "goland://open?file=" + url.QueryEscape(f.File) + "&line=" + strconv.Itoa(f.Line)
+	}
+	return ""
+}
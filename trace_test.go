@@ -0,0 +1,45 @@
+package ierror
+
+import (
+	"io"
+	"testing"
+)
+
+// deepError 递归地逐层包装，每一层都有自己的调用栈
+func deepError(n int) error {
+	if n == 0 {
+		return NewIError(1, "root")
+	}
+	return WrapIError(deepError(n-1), n+1, "layer")
+}
+
+func BenchmarkTrace(b *testing.B) {
+	err := deepError(50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = Trace(err)
+	}
+}
+
+func BenchmarkWriteTrace(b *testing.B) {
+	err := deepError(50)
+	for _, bm := range []struct {
+		name   string
+		format TraceFormat
+	}{
+		{"default", FormatDefault},
+		{"compact", FormatCompact},
+		{"json", FormatJSON},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			opts := TraceOptions{Format: bm.format}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := WriteTrace(io.Discard, err, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}